@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/kakkoyun/checklocks-demo/internal/annotation"
+)
+
+// atomicTypes maps the field types +checkatomic supports to the suffix of
+// the matching sync/atomic functions.
+var atomicTypes = map[string]string{
+	"int32":   "Int32",
+	"int64":   "Int64",
+	"uint32":  "Uint32",
+	"uint64":  "Uint64",
+	"uintptr": "Uintptr",
+}
+
+// lockKind is the type of the mutex guarding a field.
+type lockKind int
+
+const (
+	mutexLock lockKind = iota
+	rwMutexLock
+)
+
+// field is one annotated struct field to generate accessors for.
+type field struct {
+	Name   string // Field name, e.g. "value".
+	Export string // Exported form, e.g. "Value".
+	Type   string // Source form of the field type.
+	Guard  string // Guarding mutex field; empty for atomic-only fields.
+	RW     bool   // Guard is a sync.RWMutex.
+	Atomic string // sync/atomic suffix for +checkatomic fields; empty otherwise.
+	// Clone is "maps" or "slices" for map and slice fields, whose getter and
+	// setter copy the value so that it is never shared outside the lock.
+	Clone string
+}
+
+// structInfo is everything the template needs for one struct.
+type structInfo struct {
+	Name     string  // Type name, e.g. "GenericResource".
+	Recv     string  // Receiver name, e.g. "gr".
+	RecvType string  // Receiver type, e.g. "GenericResource[T]".
+	Fields   []field // Annotated fields in declaration order.
+}
+
+// generate parses the non-test Go files in dir and returns the formatted
+// accessor source for the named struct types. skip names a file to leave
+// out, normally the previous output.
+func generate(dir string, types []string, skip string) ([]byte, error) {
+	fset := token.NewFileSet()
+	files, pkgName, err := parsePackage(fset, dir, skip)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := methodNames(files)
+	var structs []structInfo
+	imports := make(map[string]bool)
+	for _, name := range types {
+		info, err := findStruct(fset, files, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range generatedMethods(info) {
+			if existing[info.Name+"."+m] {
+				return nil, fmt.Errorf("lockgen: %s already has a method %s", info.Name, m)
+			}
+		}
+		for _, f := range info.Fields {
+			if f.Atomic != "" {
+				imports["sync/atomic"] = true
+			}
+			if f.Clone != "" {
+				imports[f.Clone] = true
+			}
+		}
+		structs = append(structs, info)
+	}
+
+	var buf bytes.Buffer
+	err = fileTemplate.Execute(&buf, map[string]any{
+		"Args":    strings.Join(types, ","),
+		"Package": pkgName,
+		"Imports": slices.Sorted(maps.Keys(imports)),
+		"Structs": structs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("lockgen: formatting output: %w\n%s", err, buf.Bytes())
+	}
+	return out, nil
+}
+
+func parsePackage(fset *token.FileSet, dir, skip string) ([]*ast.File, string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, "", err
+	}
+	var (
+		files   []*ast.File
+		pkgName string
+	)
+	for _, path := range matches {
+		if strings.HasSuffix(path, "_test.go") || filepath.Base(path) == skip {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, "", err
+		}
+		if pkgName == "" {
+			pkgName = f.Name.Name
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, "", fmt.Errorf("lockgen: no Go files in %s", dir)
+	}
+	return files, pkgName, nil
+}
+
+// methodNames returns "Type.Method" for every method declared in files.
+func methodNames(files []*ast.File) map[string]bool {
+	names := make(map[string]bool)
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || len(fn.Recv.List) == 0 {
+				continue
+			}
+			names[annotation.FuncName(fn)] = true
+		}
+	}
+	return names
+}
+
+func findStruct(fset *token.FileSet, files []*ast.File, name string) (structInfo, error) {
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.Name.Name != name {
+					continue
+				}
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					return structInfo{}, fmt.Errorf("lockgen: %s is not a struct", name)
+				}
+				return analyzeStruct(fset, ts, st)
+			}
+		}
+	}
+	return structInfo{}, fmt.Errorf("lockgen: struct %s not found", name)
+}
+
+func analyzeStruct(fset *token.FileSet, ts *ast.TypeSpec, st *ast.StructType) (structInfo, error) {
+	info := structInfo{
+		Name:     ts.Name.Name,
+		Recv:     receiverName(ts.Name.Name),
+		RecvType: ts.Name.Name,
+	}
+	if ts.TypeParams != nil {
+		var params []string
+		for _, p := range ts.TypeParams.List {
+			for _, n := range p.Names {
+				params = append(params, n.Name)
+			}
+		}
+		info.RecvType += "[" + strings.Join(params, ", ") + "]"
+	}
+
+	// First pass: find the mutex fields annotations may refer to.
+	locks := make(map[string]lockKind)
+	for _, f := range st.Fields.List {
+		var kind lockKind
+		switch exprString(fset, f.Type) {
+		case "sync.Mutex":
+			kind = mutexLock
+		case "sync.RWMutex":
+			kind = rwMutexLock
+		default:
+			continue
+		}
+		for _, n := range f.Names {
+			locks[n.Name] = kind
+		}
+	}
+
+	for _, f := range st.Fields.List {
+		g := annotation.FieldGuard(f)
+		if len(g.Mutexes) == 0 && !g.Atomic {
+			continue
+		}
+		if len(g.Mutexes) > 1 {
+			return structInfo{}, fmt.Errorf("lockgen: %s: field with more than one +checklocks guard is not supported", fset.Position(f.Pos()))
+		}
+		typ := exprString(fset, f.Type)
+		for _, n := range f.Names {
+			fd := field{Name: n.Name, Export: exportName(n.Name), Type: typ, Clone: clonePackage(f.Type)}
+			if len(g.Mutexes) == 1 {
+				kind, ok := locks[g.Mutexes[0]]
+				if !ok {
+					return structInfo{}, fmt.Errorf("lockgen: %s: %s is guarded by unknown mutex %q", fset.Position(n.Pos()), n.Name, g.Mutexes[0])
+				}
+				fd.Guard = g.Mutexes[0]
+				fd.RW = kind == rwMutexLock
+			}
+			if g.Atomic {
+				suffix, ok := atomicTypes[typ]
+				if !ok {
+					return structInfo{}, fmt.Errorf("lockgen: %s: +checkatomic field %s has unsupported type %s", fset.Position(n.Pos()), n.Name, typ)
+				}
+				fd.Atomic = suffix
+			}
+			info.Fields = append(info.Fields, fd)
+		}
+	}
+	if len(info.Fields) == 0 {
+		return structInfo{}, fmt.Errorf("lockgen: struct %s has no +checklocks or +checkatomic fields", info.Name)
+	}
+	return info, nil
+}
+
+// generatedMethods lists the method names the template emits for info.
+func generatedMethods(info structInfo) []string {
+	var names []string
+	for _, f := range info.Fields {
+		names = append(names, "Get"+f.Export, "Set"+f.Export)
+		if f.Guard != "" {
+			names = append(names, f.Name+"Locked", "set"+f.Export+"Locked")
+		} else {
+			names = append(names, "Add"+f.Export)
+		}
+	}
+	return names
+}
+
+func exprString(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+	format.Node(&buf, fset, expr)
+	return buf.String()
+}
+
+func exportName(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// receiverName returns the lowercase initials of a CamelCase type name,
+// matching the pr/gr/ngr receivers used in this repository.
+func receiverName(typeName string) string {
+	var initials []rune
+	for i, r := range typeName {
+		if i == 0 || unicode.IsUpper(r) {
+			initials = append(initials, unicode.ToLower(r))
+		}
+	}
+	// Avoid clashing with the v and delta parameters of generated methods.
+	if s := string(initials); s != "v" && s != "delta" {
+		return s
+	}
+	return "r"
+}
+
+// clonePackage returns the package whose Clone copies a value of type x:
+// "maps" for a map, "slices" for a slice, and "" for anything else.
+func clonePackage(x ast.Expr) string {
+	switch t := x.(type) {
+	case *ast.MapType:
+		return "maps"
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "slices"
+		}
+	}
+	return ""
+}
+
+// defaultOutput returns the output file name for types.
+func defaultOutput(types []string) string {
+	return strings.ToLower(types[0]) + "_lockgen.go"
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by lockgen -type {{.Args}}; DO NOT EDIT.
+
+package {{.Package}}
+{{with .Imports}}
+import ({{range .}}
+	"{{.}}"{{end}}
+)
+{{end}}
+{{- range $s := .Structs}}
+{{- range $f := .Fields}}
+{{- if and $f.Guard $f.Atomic}}
+
+// Get{{$f.Export}} atomically loads {{$s.Recv}}.{{$f.Name}}; reads need the lock or an atomic load.
+func ({{$s.Recv}} *{{$s.RecvType}}) Get{{$f.Export}}() {{$f.Type}} {
+	return atomic.Load{{$f.Atomic}}(&{{$s.Recv}}.{{$f.Name}})
+}
+
+// Set{{$f.Export}} atomically stores {{$s.Recv}}.{{$f.Name}} with {{$s.Recv}}.{{$f.Guard}} held; writes need both.
+func ({{$s.Recv}} *{{$s.RecvType}}) Set{{$f.Export}}(v {{$f.Type}}) {
+	{{$s.Recv}}.{{$f.Guard}}.Lock()
+	atomic.Store{{$f.Atomic}}(&{{$s.Recv}}.{{$f.Name}}, v)
+	{{$s.Recv}}.{{$f.Guard}}.Unlock()
+}
+
+// {{$f.Name}}Locked returns {{$s.Recv}}.{{$f.Name}}.
+// +checklocks:{{$s.Recv}}.{{$f.Guard}}
+func ({{$s.Recv}} *{{$s.RecvType}}) {{$f.Name}}Locked() {{$f.Type}} {
+	return {{$s.Recv}}.{{$f.Name}}
+}
+
+// set{{$f.Export}}Locked atomically stores {{$s.Recv}}.{{$f.Name}}.
+// +checklocks:{{$s.Recv}}.{{$f.Guard}}
+func ({{$s.Recv}} *{{$s.RecvType}}) set{{$f.Export}}Locked(v {{$f.Type}}) {
+	atomic.Store{{$f.Atomic}}(&{{$s.Recv}}.{{$f.Name}}, v)
+}
+{{- else if $f.Guard}}
+
+// Get{{$f.Export}} returns {{if $f.Clone}}a copy of {{end}}{{$s.Recv}}.{{$f.Name}}, {{if $f.RW}}read-{{end}}locking {{$s.Recv}}.{{$f.Guard}}.
+func ({{$s.Recv}} *{{$s.RecvType}}) Get{{$f.Export}}() {{$f.Type}} {
+	{{$s.Recv}}.{{$f.Guard}}.{{if $f.RW}}RLock{{else}}Lock{{end}}()
+	v := {{if $f.Clone}}{{$f.Clone}}.Clone({{$s.Recv}}.{{$f.Name}}){{else}}{{$s.Recv}}.{{$f.Name}}{{end}}
+	{{$s.Recv}}.{{$f.Guard}}.{{if $f.RW}}RUnlock{{else}}Unlock{{end}}()
+	return v
+}
+
+// Set{{$f.Export}} sets {{$s.Recv}}.{{$f.Name}}{{if $f.Clone}} to a copy of v{{end}}, locking {{$s.Recv}}.{{$f.Guard}}.
+func ({{$s.Recv}} *{{$s.RecvType}}) Set{{$f.Export}}(v {{$f.Type}}) {
+	{{$s.Recv}}.{{$f.Guard}}.Lock()
+	{{$s.Recv}}.{{$f.Name}} = {{if $f.Clone}}{{$f.Clone}}.Clone(v){{else}}v{{end}}
+	{{$s.Recv}}.{{$f.Guard}}.Unlock()
+}
+
+// {{$f.Name}}Locked returns {{$s.Recv}}.{{$f.Name}}.
+// +checklocks{{if $f.RW}}read{{end}}:{{$s.Recv}}.{{$f.Guard}}
+func ({{$s.Recv}} *{{$s.RecvType}}) {{$f.Name}}Locked() {{$f.Type}} {
+	return {{$s.Recv}}.{{$f.Name}}
+}
+
+// set{{$f.Export}}Locked sets {{$s.Recv}}.{{$f.Name}}.
+// +checklocks:{{$s.Recv}}.{{$f.Guard}}
+func ({{$s.Recv}} *{{$s.RecvType}}) set{{$f.Export}}Locked(v {{$f.Type}}) {
+	{{$s.Recv}}.{{$f.Name}} = v
+}
+{{- else}}
+
+// Get{{$f.Export}} atomically loads {{$s.Recv}}.{{$f.Name}}.
+func ({{$s.Recv}} *{{$s.RecvType}}) Get{{$f.Export}}() {{$f.Type}} {
+	return atomic.Load{{$f.Atomic}}(&{{$s.Recv}}.{{$f.Name}})
+}
+
+// Set{{$f.Export}} atomically stores {{$s.Recv}}.{{$f.Name}}.
+func ({{$s.Recv}} *{{$s.RecvType}}) Set{{$f.Export}}(v {{$f.Type}}) {
+	atomic.Store{{$f.Atomic}}(&{{$s.Recv}}.{{$f.Name}}, v)
+}
+
+// Add{{$f.Export}} atomically adds delta to {{$s.Recv}}.{{$f.Name}} and returns the new value.
+func ({{$s.Recv}} *{{$s.RecvType}}) Add{{$f.Export}}(delta {{$f.Type}}) {{$f.Type}} {
+	return atomic.Add{{$f.Atomic}}(&{{$s.Recv}}.{{$f.Name}}, delta)
+}
+{{- end}}
+{{- end}}
+{{- end}}
+`))
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestGenerateGolden(t *testing.T) {
+	got, err := generate("testdata/config", []string{"Config", "Box"}, "")
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	golden := filepath.Join("testdata", "config", "config_lockgen.go.golden")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -update to create it): %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("generated output differs from %s; rerun with -update if intended.\ngot:\n%s", golden, got)
+	}
+}
+
+// TestGeneratedCodeTypeChecks compiles the fixture together with the
+// generated accessors.
+func TestGeneratedCodeTypeChecks(t *testing.T) {
+	src, err := generate("testdata/config", []string{"Config", "Box"}, "")
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	fset := token.NewFileSet()
+	fixture, err := parser.ParseFile(fset, "testdata/config/config.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated, err := parser.ParseFile(fset, "config_lockgen.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("config", fset, []*ast.File{fixture, generated}, nil); err != nil {
+		t.Errorf("generated code does not type-check: %v", err)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for _, tc := range []struct {
+		dir, typ, want string
+	}{
+		{"testdata/config", "Missing", "struct Missing not found"},
+		{"testdata/conflict", "Counter", "already has a method GetCount"},
+	} {
+		_, err := generate(tc.dir, []string{tc.typ}, "")
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("generate(%s, %s): expected error containing %q, got %v", tc.dir, tc.typ, tc.want, err)
+		}
+	}
+}
+
+func TestReceiverName(t *testing.T) {
+	for typ, want := range map[string]string{
+		"ProtectedResource":  "pr",
+		"GenericResource":    "gr",
+		"NonGenericResource": "ngr",
+		"Value":              "r",
+	} {
+		if got := receiverName(typ); got != want {
+			t.Errorf("receiverName(%s): expected %s, got %s", typ, want, got)
+		}
+	}
+}
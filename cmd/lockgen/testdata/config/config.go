@@ -0,0 +1,30 @@
+package config
+
+import "sync"
+
+// Config exercises every kind of annotated field lockgen supports.
+type Config struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	name string
+
+	rwMu sync.RWMutex
+	// +checklocks:rwMu
+	limits []int
+
+	// +checkatomic
+	hits int64
+
+	// +checkatomic
+	// +checklocks:mu
+	generation uint32
+
+	owner string // Not annotated; no accessors.
+}
+
+// Box checks generic receivers.
+type Box[K comparable, V any] struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	items map[K]V
+}
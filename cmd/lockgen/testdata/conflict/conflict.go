@@ -0,0 +1,16 @@
+package conflict
+
+import "sync"
+
+type Counter struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	count int
+}
+
+// GetCount already exists, so lockgen must refuse to generate it.
+func (c *Counter) GetCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
@@ -0,0 +1,25 @@
+// Command lockinfer proposes checklocks annotations for a package that does
+// not have them yet:
+//
+//	go run ./cmd/lockinfer ./pkg/...
+//
+// prints each proposal with its confidence, and
+//
+//	go run ./cmd/lockinfer -fix -min-confidence=0.9 ./pkg/...
+//
+// inserts the proposed // +checklocks, // +checklocksread and
+// // +checkatomic lines into the source. Review the result and run
+// checklocks on it: a proposal below 100% confidence means some access or
+// call site does not hold the lock, and checklocks will now report it.
+//
+// It is a separate command rather than part of lockvet because its
+// proposals are suggestions, not violations.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"github.com/kakkoyun/checklocks-demo/pkg/analysis/lockinfer"
+)
+
+func main() { singlechecker.Main(lockinfer.Analyzer) }
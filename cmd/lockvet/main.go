@@ -0,0 +1,73 @@
+// Command lockvet bundles checklocks and this module's lock analyzers into a
+// single vet tool, versioned with the module so that lint runs are
+// reproducible and need no network:
+//
+//	go build -o bin/lockvet ./cmd/lockvet
+//	go vet -vettool=bin/lockvet ./...
+//
+// It can also be run directly (`lockvet ./...`), in which case it loads the
+// packages itself.
+//
+// `lockvet report` writes its findings as SARIF 2.1.0, JSON or text for
+// code-scanning uploads, optionally filtered through a baseline of known
+// findings; see reportUsage.
+//
+// The analyzers are:
+//
+//	checklocks    accesses to +checklocks and +checkatomic fields without
+//	              the lock held or atomically; gvisor's, at the go-branch
+//	              commit pinned in go.mod
+//	lockorder     acquisitions that violate a +lockorder struct annotation
+//	ignoreassert  +checklocksignore functions that do not assert, at entry,
+//	              the locks guarding the fields they access
+//	forceaudit    +checklocksforce sites that are unused or leak past a
+//	              return; lockvet report also lists every site with the
+//	              accesses relying on it
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/multichecker"
+	"gvisor.dev/gvisor/tools/checklocks"
+
+	"github.com/kakkoyun/checklocks-demo/pkg/analysis/forceaudit"
+	"github.com/kakkoyun/checklocks-demo/pkg/analysis/ignoreassert"
+	"github.com/kakkoyun/checklocks-demo/pkg/analysis/lockorder"
+)
+
+// analyzers is the suite run by lockvet.
+var analyzers = []*analysis.Analyzer{
+	checklocks.Analyzer,
+	lockorder.Analyzer,
+	ignoreassert.Analyzer,
+	forceaudit.Analyzer,
+}
+
+func main() {
+	// go vet only ever passes flags it knows about or a .cfg file, so a
+	// leading "report" or baseline flag cannot be mistaken for a vet
+	// invocation.
+	var err error
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "report":
+		err = report(os.Args[2:], "sarif")
+	case len(os.Args) > 1 && isBaselineFlag(os.Args[1]):
+		err = report(os.Args[1:], "text")
+	default:
+		multichecker.Main(analyzers...)
+		return
+	}
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errReported):
+		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "lockvet: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestAnalyzersValid(t *testing.T) {
+	if err := analysis.Validate(analyzers); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsBaselineFlag(t *testing.T) {
+	for arg, want := range map[string]bool{
+		"-baseline":             true,
+		"--write-baseline=file": true,
+		"-write-baseline":       true,
+		"baseline":              false,
+		"-V=full":               false,
+		"./...":                 false,
+	} {
+		if got := isBaselineFlag(arg); got != want {
+			t.Errorf("isBaselineFlag(%q) = %v, want %v", arg, got, want)
+		}
+	}
+}
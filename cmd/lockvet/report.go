@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kakkoyun/checklocks-demo/internal/lintreport"
+	"github.com/kakkoyun/checklocks-demo/internal/vetrun"
+)
+
+const reportUsage = `usage: lockvet report [flags] [packages]
+       lockvet -baseline file [flags] [packages]
+       lockvet -write-baseline file [flags] [packages]
+
+Runs go vet with lockvet, checklocks included, over packages (default
+./...) and writes the findings as SARIF 2.1.0, JSON or text. Paths are
+relative to the current directory, which should be the repository root.
+
+With -baseline, findings recorded in the baseline file are not reported;
+baseline entries that no longer match anything are reported as stale, so
+the file shrinks as violations are fixed. -write-baseline records the
+current findings instead of reporting them. Entries are keyed by package,
+enclosing function, rule and fields, not by line; findings without a
+package and function cannot be recorded and must be fixed. The two
+baseline forms without "report" default to -format=text.
+
+With -format=text the exit status is 1 if anything was reported, as with
+go vet, except for the force-site inventory, which is listed for review
+and never recorded in a baseline. SARIF and JSON reports exit zero
+whenever they are written, so CI can upload them and let code scanning
+decide what fails. Code scanning needs a location for every result, so
+findings without a position are left out of a SARIF report and listed on
+stderr instead, and the exit status is then 1.
+
+Flags:
+`
+
+// errReported is returned when a text report is not empty.
+var errReported = errors.New("findings reported")
+
+// isBaselineFlag reports whether arg is a -baseline or -write-baseline flag,
+// which may start a report run without the "report" subcommand.
+func isBaselineFlag(arg string) bool {
+	name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+	return strings.HasPrefix(arg, "-") && (name == "baseline" || name == "write-baseline")
+}
+
+// report implements `lockvet report`.
+func report(args []string, format string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), reportUsage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&format, "format", format, "output format: sarif, json or text")
+	out := fs.String("o", "", "write the report to `file` instead of stdout")
+	tags := fs.String("tags", "", "build tags passed to go vet")
+	baseline := fs.String("baseline", "", "do not report findings recorded in `file`")
+	writeBaseline := fs.String("write-baseline", "", "record the current findings in `file` instead of reporting them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var write func(io.Writer, []lintreport.Finding, []lintreport.Entry) error
+	var omitted []lintreport.Finding // Left out of a SARIF report.
+	switch format {
+	case "sarif":
+		write = func(w io.Writer, fs []lintreport.Finding, _ []lintreport.Entry) (err error) {
+			omitted, err = lintreport.WriteSARIF(w, fs)
+			return err
+		}
+	case "json":
+		write = lintreport.WriteJSON
+	case "text":
+		write = lintreport.WriteText
+	default:
+		return fmt.Errorf("unknown -format %q", format)
+	}
+	if *baseline != "" && *writeBaseline != "" {
+		return errors.New("-baseline and -write-baseline are mutually exclusive")
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	var vetArgs []string
+	if *tags != "" {
+		vetArgs = append(vetArgs, "-tags", *tags)
+	}
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	vetArgs = append(vetArgs, patterns...)
+
+	// The report includes the force-site inventory, which plain vet runs
+	// leave out.
+	diags, err := vetrun.Vet(self, root, append([]string{"-forceaudit.inventory"}, vetArgs...)...)
+	if err != nil {
+		return err
+	}
+	findings := lintreport.ClassifyAll(root, diags)
+
+	if *writeBaseline != "" {
+		// Build the baseline first, so a rejected one leaves the file as is.
+		var buf bytes.Buffer
+		if err := lintreport.WriteBaseline(&buf, findings); err != nil {
+			return err
+		}
+		if err := os.WriteFile(*writeBaseline, buf.Bytes(), 0o666); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "lockvet: recorded %d finding(s) in %s\n", len(findings), *writeBaseline)
+		return nil
+	}
+	var stale []lintreport.Entry
+	if *baseline != "" {
+		f, err := os.Open(*baseline)
+		if err != nil {
+			return err
+		}
+		b, err := lintreport.ReadBaseline(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", *baseline, err)
+		}
+		findings, stale = b.Filter(findings)
+	}
+
+	if *out != "" {
+		err = writeFile(*out, func(w io.Writer) error { return write(w, findings, stale) })
+	} else {
+		err = write(os.Stdout, findings, stale)
+	}
+	if err != nil {
+		return err
+	}
+	if format == "text" {
+		for _, f := range findings {
+			if !lintreport.Inventory(f.Rule) {
+				return errReported
+			}
+		}
+		if len(stale) > 0 {
+			return errReported
+		}
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "lockvet report: %d finding(s), %d stale baseline entries\n", len(findings), len(stale))
+	if len(omitted) > 0 {
+		fmt.Fprintf(os.Stderr, "lockvet report: %d finding(s) without a position left out of the SARIF report:\n", len(omitted))
+		if err := lintreport.WriteText(os.Stderr, omitted, nil); err != nil {
+			return err
+		}
+		return errReported
+	}
+	return nil
+}
+
+// writeFile creates name and writes it with fn.
+func writeFile(name string, fn func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -1,5 +1,14 @@
 module github.com/kakkoyun/checklocks-demo
 
-go 1.24.2
+go 1.26.3
 
-require github.com/trailofbits/go-mutexasserts v0.0.0-20250212181730-4c2b8e9e784b
+require (
+	github.com/trailofbits/go-mutexasserts v0.0.0-20250212181730-4c2b8e9e784b
+	golang.org/x/tools v0.47.0
+	gvisor.dev/gvisor v0.0.0-20260527191743-a81fd9dd382e
+)
+
+require (
+	golang.org/x/mod v0.37.0 // indirect
+	golang.org/x/sync v0.21.0 // indirect
+)
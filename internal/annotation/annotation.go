@@ -0,0 +1,157 @@
+// Package annotation parses the checklocks and lock-order annotations read
+// by the module's analyzers and tools, and recognizes the mutex types they
+// refer to, so that every tool reads an annotation the same way.
+package annotation
+
+import (
+	"go/ast"
+	"go/types"
+	"slices"
+	"strings"
+)
+
+// Parse returns the annotation a comment starts with, without the comment
+// marker and with "+x: y" normalized to "+x:y", e.g. "+checklocksforce:pr.mu"
+// for "// +checklocksforce: pr.mu". Text after the annotation is dropped.
+func Parse(comment string) (string, bool) {
+	words := strings.Fields(strings.TrimPrefix(comment, "//"))
+	if len(words) == 0 || !strings.HasPrefix(words[0], "+") {
+		return "", false
+	}
+	if strings.HasSuffix(words[0], ":") && len(words) > 1 {
+		return words[0] + words[1], true
+	}
+	return words[0], true
+}
+
+// List returns the annotations in groups, in order, as Parse returns them.
+func List(groups ...*ast.CommentGroup) []string {
+	var out []string
+	for _, cg := range groups {
+		if cg == nil {
+			continue
+		}
+		for _, cm := range cg.List {
+			if text, ok := Parse(cm.Text); ok {
+				out = append(out, text)
+			}
+		}
+	}
+	return out
+}
+
+// Has reports whether doc carries marker, such as "+checklocksignore".
+func Has(doc *ast.CommentGroup, marker string) bool {
+	return slices.Contains(List(doc), marker)
+}
+
+// Guard is how a struct field's annotations say it is protected.
+type Guard struct {
+	Mutexes []string // Sibling mutexes named by +checklocks annotations.
+	Atomic  bool     // +checkatomic: atomic reads need no lock.
+}
+
+func (g Guard) String() string {
+	s := "guarded(" + strings.Join(g.Mutexes, ",") + ")"
+	if g.Atomic {
+		s += " atomic"
+	}
+	return s
+}
+
+// FieldGuard returns the guard declared in a struct field's doc and line
+// comments, which checklocks both reads.
+func FieldGuard(f *ast.Field) Guard {
+	var g Guard
+	for _, text := range List(f.Doc, f.Comment) {
+		if mu, ok := strings.CutPrefix(text, "+checklocks:"); ok {
+			g.Mutexes = append(g.Mutexes, mu)
+		} else if text == "+checkatomic" {
+			g.Atomic = true
+		}
+	}
+	return g
+}
+
+// Guards returns the guard of every field of files' struct types that names
+// at least one mutex.
+func Guards(files []*ast.File, info *types.Info) map[*types.Var]Guard {
+	guards := make(map[*types.Var]Guard)
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			st, ok := n.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			for _, f := range st.Fields.List {
+				g := FieldGuard(f)
+				if len(g.Mutexes) == 0 {
+					continue
+				}
+				for _, name := range f.Names {
+					if v, ok := info.Defs[name].(*types.Var); ok {
+						guards[v] = g
+					}
+				}
+			}
+			return true
+		})
+	}
+	return guards
+}
+
+// IsMutexName reports whether pkgPath.name is sync.Mutex or sync.RWMutex.
+func IsMutexName(pkgPath, name string) bool {
+	return pkgPath == "sync" && (name == "Mutex" || name == "RWMutex")
+}
+
+// IsMutex reports whether t is sync.Mutex or sync.RWMutex.
+func IsMutex(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Pkg() != nil && IsMutexName(n.Obj().Pkg().Path(), n.Obj().Name())
+}
+
+// IsRWMutex reports whether t is sync.RWMutex.
+func IsRWMutex(t types.Type) bool {
+	return IsMutex(t) && t.(*types.Named).Obj().Name() == "RWMutex"
+}
+
+// Deref returns the element type of a pointer type, and any other type
+// unchanged.
+func Deref(t types.Type) types.Type {
+	if p, ok := t.(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+// FuncName returns "Type.Method" for a method and the name for a function.
+func FuncName(fd *ast.FuncDecl) string {
+	if fd.Recv != nil && len(fd.Recv.List) > 0 {
+		if t := RecvName(fd.Recv.List[0].Type); t != "" {
+			return t + "." + fd.Name.Name
+		}
+	}
+	return fd.Name.Name
+}
+
+// RecvName returns the type name of a method receiver, without pointer or
+// type parameters.
+func RecvName(x ast.Expr) string {
+	for {
+		switch t := x.(type) {
+		case *ast.StarExpr:
+			x = t.X
+		case *ast.IndexExpr:
+			x = t.X
+		case *ast.IndexListExpr:
+			x = t.X
+		case *ast.ParenExpr:
+			x = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
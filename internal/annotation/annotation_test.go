@@ -0,0 +1,57 @@
+package annotation
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		comment, want string
+		ok            bool
+	}{
+		{"// +checklocks:mu", "+checklocks:mu", true},
+		{"// +checklocksforce: pr.mu", "+checklocksforce:pr.mu", true},
+		{"//+checkatomic", "+checkatomic", true},
+		{"// +checklocksignore because it is a demo", "+checklocksignore", true},
+		{"// Guards lists the mutexes.", "", false},
+		{"// We use +checklocksignore because", "", false},
+		{"//", "", false},
+	} {
+		got, ok := Parse(tt.comment)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Parse(%q) = %q, %v; want %q, %v", tt.comment, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFieldGuard(t *testing.T) {
+	const src = `package p
+
+type T struct {
+	// +checklocks:mu
+	// +checkatomic
+	a int32
+	b int // +checklocks: rw
+	// Not guarded.
+	c int
+}`
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type.(*ast.StructType).Fields.List
+	for i, want := range []Guard{
+		{Mutexes: []string{"mu"}, Atomic: true},
+		{Mutexes: []string{"rw"}},
+		{},
+	} {
+		got := FieldGuard(fields[i])
+		if !slices.Equal(got.Mutexes, want.Mutexes) || got.Atomic != want.Atomic {
+			t.Errorf("FieldGuard(%s) = %v, want %v", fields[i].Names[0], got, want)
+		}
+	}
+}
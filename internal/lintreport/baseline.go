@@ -0,0 +1,179 @@
+package lintreport
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// An Entry is one line of a baseline file: a kind of finding, identified by
+// package directory, enclosing function, rule and fields rather than by
+// line, so that unrelated edits do not invalidate it, and the number of
+// such findings that are known.
+type Entry struct {
+	Package  string `json:"package"`
+	Function string `json:"function"`
+	Rule     string `json:"rule"`
+	Fields   string `json:"fields"` // Comma-separated.
+	Count    int    `json:"count"`
+}
+
+type entryKey struct {
+	pkg, fn, rule, fields string
+}
+
+func (e Entry) key() entryKey { return entryKey{e.Package, e.Function, e.Rule, e.Fields} }
+
+// String formats e as a baseline line: package, function, rule and fields
+// separated by spaces, with "-" for empty columns and an "xN" suffix for
+// repeated findings.
+func (e Entry) String() string {
+	s := strings.Join([]string{dash(e.Package), dash(e.Function), e.Rule, dash(e.Fields)}, " ")
+	if e.Count > 1 {
+		s += " x" + strconv.Itoa(e.Count)
+	}
+	return s
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func undash(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// entryFor returns the baseline entry, with a count of one, for f.
+func entryFor(f Finding) Entry {
+	pkg := ""
+	if f.File != "" {
+		pkg = path.Dir(f.File)
+	}
+	return Entry{Package: pkg, Function: f.Function, Rule: f.Rule, Fields: strings.Join(f.Fields, ","), Count: 1}
+}
+
+// A Baseline is a set of known findings.
+type Baseline struct {
+	entries map[entryKey]Entry
+}
+
+const baselineHeader = `# lockvet baseline: known findings that are not reported.
+# Regenerate with: lockvet report -write-baseline <file> [packages]
+# package function rule fields [xcount]
+`
+
+// ReadBaseline parses a baseline file. Blank lines and lines starting with
+// '#' are ignored.
+func ReadBaseline(r io.Reader) (*Baseline, error) {
+	b := &Baseline{entries: make(map[entryKey]Entry)}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cols := strings.Fields(line)
+		e := Entry{Count: 1}
+		switch len(cols) {
+		case 5:
+			c, err := strconv.Atoi(strings.TrimPrefix(cols[4], "x"))
+			if err != nil || !strings.HasPrefix(cols[4], "x") || c < 1 {
+				return nil, fmt.Errorf("baseline line %d: bad count %q", n, cols[4])
+			}
+			e.Count = c
+		case 4:
+		default:
+			return nil, fmt.Errorf("baseline line %d: want 4 or 5 columns, got %d", n, len(cols))
+		}
+		e.Package, e.Function, e.Rule, e.Fields = undash(cols[0]), undash(cols[1]), cols[2], undash(cols[3])
+		if e.Package == "" || e.Function == "" {
+			return nil, fmt.Errorf("baseline line %d: package and function are required", n)
+		}
+		if old, ok := b.entries[e.key()]; ok {
+			e.Count += old.Count
+		}
+		b.entries[e.key()] = e
+	}
+	return b, sc.Err()
+}
+
+// WriteBaseline writes a baseline that suppresses exactly findings, leaving
+// out Inventory findings. It writes nothing and returns an error if a
+// finding has no package or function, since its entry would suppress that
+// rule anywhere in the package or the module; such findings have to be
+// fixed.
+func WriteBaseline(w io.Writer, findings []Finding) error {
+	var unkeyed []string
+	counts := make(map[entryKey]Entry)
+	for _, f := range findings {
+		if Inventory(f.Rule) {
+			continue
+		}
+		e := entryFor(f)
+		if e.Package == "" || e.Function == "" {
+			unkeyed = append(unkeyed, fmt.Sprintf("%s:%d: %s", dash(f.File), f.Line, f.Message))
+			continue
+		}
+		if old, ok := counts[e.key()]; ok {
+			e.Count += old.Count
+		}
+		counts[e.key()] = e
+	}
+	if len(unkeyed) > 0 {
+		return fmt.Errorf("cannot baseline %d finding(s) without a package and function:\n\t%s", len(unkeyed), strings.Join(unkeyed, "\n\t"))
+	}
+	entries := slices.SortedFunc(maps.Values(counts), func(a, b Entry) int {
+		return cmp.Or(
+			cmp.Compare(a.Package, b.Package),
+			cmp.Compare(a.Function, b.Function),
+			cmp.Compare(a.Rule, b.Rule),
+			cmp.Compare(a.Fields, b.Fields),
+		)
+	})
+	bw := bufio.NewWriter(w)
+	bw.WriteString(baselineHeader)
+	for _, e := range entries {
+		fmt.Fprintln(bw, e)
+	}
+	return bw.Flush()
+}
+
+// Filter returns the findings not covered by b, in order, and the baseline
+// entries, or parts of entries, that no longer match any finding. When a
+// function has more findings of a kind than its entry counts, the later
+// ones are reported. Inventory findings are always returned.
+func (b *Baseline) Filter(findings []Finding) (fresh []Finding, stale []Entry) {
+	left := maps.Clone(b.entries)
+	for _, f := range findings {
+		if Inventory(f.Rule) {
+			fresh = append(fresh, f)
+			continue
+		}
+		k := entryFor(f).key()
+		if e, ok := left[k]; ok && e.Count > 0 {
+			e.Count--
+			left[k] = e
+			continue
+		}
+		fresh = append(fresh, f)
+	}
+	for _, e := range left {
+		if e.Count > 0 {
+			stale = append(stale, e)
+		}
+	}
+	slices.SortFunc(stale, func(a, b Entry) int { return strings.Compare(a.String(), b.String()) })
+	return fresh, stale
+}
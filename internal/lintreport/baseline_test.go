@@ -0,0 +1,130 @@
+package lintreport
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestBaselineRoundTrip(t *testing.T) {
+	findings := []Finding{
+		{File: "pkg/resource/resource.go", Line: 70, Function: "ProtectedResource.IncorrectSetData", Rule: "lock-not-held", Fields: []string{"value", "mu"}},
+		{File: "pkg/resource/resource.go", Line: 180, Function: "ProtectedResource.IncorrectDirectWriteAtomic", Rule: "non-atomic-access"},
+		{File: "pkg/resource/resource.go", Line: 181, Function: "ProtectedResource.IncorrectDirectWriteAtomic", Rule: "non-atomic-access"},
+		{File: "pkg/resource/resource.go", Line: 300, Function: "ProtectedResource.ForceExample", Rule: "lock-leaked", Fields: []string{"mu"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteBaseline(&buf, findings); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"pkg/resource ProtectedResource.IncorrectDirectWriteAtomic non-atomic-access - x2\n",
+		"pkg/resource ProtectedResource.IncorrectSetData lock-not-held value,mu\n",
+		"pkg/resource ProtectedResource.ForceExample lock-leaked mu\n",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("baseline is missing %q:\n%s", line, buf.String())
+		}
+	}
+	b, err := ReadBaseline(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same findings, moved by unrelated edits, are all suppressed.
+	for i := range findings {
+		findings[i].Line += 10
+	}
+	if fresh, stale := b.Filter(findings); len(fresh) != 0 || len(stale) != 0 {
+		t.Errorf("Filter(same findings) = %v, %v; want nothing", fresh, stale)
+	}
+
+	// A third non-atomic access is new; a fixed one leaves a stale entry.
+	extra := findings[1]
+	extra.Line = 500
+	fresh, stale := b.Filter([]Finding{findings[1], findings[2], extra, findings[3]})
+	if len(fresh) != 1 || fresh[0].Line != 500 {
+		t.Errorf("fresh = %v, want the finding on line 500", fresh)
+	}
+	if len(stale) != 1 || stale[0].Function != "ProtectedResource.IncorrectSetData" || stale[0].Count != 1 {
+		t.Errorf("stale = %v, want the IncorrectSetData entry", stale)
+	}
+}
+
+func TestBaselineSkipsInventory(t *testing.T) {
+	site := Finding{File: "pkg/resource/context.go", Line: 68, Function: "ProtectedResource.SetDataCtx", Rule: "force-site", Fields: []string{"mu"}}
+	var buf bytes.Buffer
+	if err := WriteBaseline(&buf, []Finding{site}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "force-site") {
+		t.Errorf("baseline records a force-site finding:\n%s", buf.String())
+	}
+	b, err := ReadBaseline(strings.NewReader("pkg/resource ProtectedResource.SetDataCtx force-site mu\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh, _ := b.Filter([]Finding{site}); len(fresh) != 1 {
+		t.Errorf("Filter suppressed a force-site finding: fresh = %v", fresh)
+	}
+}
+
+func TestWriteBaselineRejectsUnkeyed(t *testing.T) {
+	for _, f := range []Finding{
+		{Analyzer: "checklocks", Rule: "lock-leaked", Message: "return with unexpected locks held"},
+		{File: "pkg/resource/resource.go", Line: 1, Rule: "other", Message: "outside any function"},
+	} {
+		var buf bytes.Buffer
+		if err := WriteBaseline(&buf, []Finding{f}); err == nil || buf.Len() != 0 {
+			t.Errorf("WriteBaseline(%+v) = %v, wrote %q; want an error and nothing written", f, err, buf.String())
+		}
+	}
+}
+
+func TestReadBaselineErrors(t *testing.T) {
+	for _, in := range []string{
+		"- - lock-leaked -\n",
+		"pkg - rule fields\n",
+		"pkg fn rule\n",
+		"pkg fn rule fields 2\n",
+		"pkg fn rule fields x0\n",
+	} {
+		if _, err := ReadBaseline(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadBaseline(%q) succeeded", in)
+		}
+	}
+}
+
+func TestEnclosing(t *testing.T) {
+	const src = `package p
+
+type R[T any] struct {
+	mu int
+}
+
+func (r *R[T]) Method() {
+	_ = r
+}
+
+func Func() {
+	_ = func() {}
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for line, want := range map[int]string{
+		1:  "",
+		4:  "R",
+		8:  "R.Method",
+		12: "Func",
+	} {
+		if got := enclosing(fset, f, line); got != want {
+			t.Errorf("enclosing(line %d) = %q, want %q", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+// Package lintreport turns vet tool diagnostics into structured findings and
+// writes them as JSON or SARIF 2.1.0 for code-scanning dashboards.
+//
+// checklocks and lockorder report free-form messages. Classify maps each one
+// to a stable rule ID, such as lock-not-held or non-atomic-access, and pulls
+// out the fields involved and, where the message implies one, the annotation
+// that would satisfy the analyzer.
+package lintreport
+
+import (
+	"cmp"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kakkoyun/checklocks-demo/internal/annotation"
+	"github.com/kakkoyun/checklocks-demo/internal/vetrun"
+)
+
+// Finding is one classified diagnostic. Its JSON form is the schema written
+// by WriteJSON; fields are only ever added to it.
+type Finding struct {
+	File   string `json:"file,omitempty"` // Slash-separated, relative to the report root.
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	// Function is the enclosing function, as "Type.Method" for methods, or
+	// the enclosing type for findings on a declaration such as a field.
+	Function string `json:"function,omitempty"`
+	Analyzer string `json:"analyzer"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+	// Fields lists the struct fields involved, guarded field first.
+	Fields []string `json:"fields,omitempty"`
+	// Annotation is a checklocks annotation that would address the finding,
+	// e.g. "+checklocks:pr.mu" on the enclosing function.
+	Annotation string `json:"suggestedAnnotation,omitempty"`
+}
+
+// Rule describes a rule ID.
+type Rule struct {
+	ID  string
+	Doc string
+}
+
+// Rules lists every rule Classify can assign, in a stable order.
+var Rules = []Rule{
+	{"lock-not-held", "A guarded field is accessed without holding the mutex named by its +checklocks annotation."},
+	{"acquire-precondition", "A function annotated +checklocks or +checklocksread is called without holding the lock it requires."},
+	{"non-atomic-access", "A field annotated +checklocks:atomic or +checklocksatomic is accessed without sync/atomic, or atomically where a lock is required."},
+	{"double-acquire", "A lock is acquired while it is already held."},
+	{"release-not-held", "A lock is released, or a +checklocksrelease function called, while the lock is not held."},
+	{"lock-leaked", "A function returns holding a lock it is not annotated to acquire."},
+	{"missing-annotation", "A mutex is consistently held around a field that carries no checklocks annotation."},
+	{"lock-order", "A lock is acquired while holding one declared after it by a +lockorder annotation."},
+	{"missing-assertion", "A +checklocksignore function accesses guarded fields without asserting, at entry, that their locks are held."},
+	{"force-site", "A +checklocksforce annotation, listed with the accesses that rely on it, for review."},
+	{"force-unused", "A +checklocksforce annotation that no later access relies on."},
+	{"force-leak", "A +checklocksforce annotation whose lock is still considered held at a return or the end of the function."},
+	{"invalid-annotation", "A lock annotation is malformed or refers to something that is not a mutex."},
+	{"other", "A diagnostic not covered by a more specific rule."},
+}
+
+// Inventory reports whether findings of rule list sites for review rather
+// than violations. They never fail a lint run and are never recorded in, or
+// suppressed by, a baseline.
+func Inventory(rule string) bool {
+	return rule == "force-site"
+}
+
+// pattern maps messages matching re to a rule. fields lists the submatch
+// indexes naming fields; the last element of a lock path such as "pr.mu" is
+// used unless classes is set, in which case lock classes such as
+// "ProtectedResource.mu" are kept whole. atSource marks messages that name
+// no field; ClassifyAll reads it from the source at the position instead.
+// annotate builds the suggested annotation from the submatches.
+type pattern struct {
+	re       *regexp.Regexp
+	rule     string
+	fields   []int
+	classes  bool
+	atSource bool
+	annotate func(m []string) string
+}
+
+var patterns = []pattern{
+	{
+		re:       regexp.MustCompile(`^invalid field access, (\w+) \((.*?)\) must be locked when accessing (\w+)`),
+		rule:     "lock-not-held",
+		fields:   []int{3, 1},
+		annotate: func(m []string) string { return "+checklocks:" + lockPath(m[2], m[1]) },
+	},
+	{
+		re:     regexp.MustCompile(`^must hold (\S+) (exclusively|non-exclusively) \(.*?\) to call (\S+), but not held`),
+		rule:   "acquire-precondition",
+		fields: []int{1},
+		annotate: func(m []string) string {
+			if m[2] == "non-exclusively" {
+				return "+checklocksread:" + m[1]
+			}
+			return "+checklocks:" + m[1]
+		},
+	},
+	{re: regexp.MustCompile(`^non-atomic write of field (\w+)`), rule: "non-atomic-access", fields: []int{1}},
+	{re: regexp.MustCompile(`^illegal use of atomic-only field`), rule: "non-atomic-access", atSource: true},
+	{re: regexp.MustCompile(`^unexpected call to atomic write function`), rule: "non-atomic-access", atSource: true},
+	{re: regexp.MustCompile(`^attempt to acquire (\S+) .*, but already held`), rule: "double-acquire", fields: []int{1}},
+	{re: regexp.MustCompile(`^attempt to release (\S+) .*, but not held`), rule: "release-not-held", fields: []int{1}},
+	{re: regexp.MustCompile(`^return with unexpected locks held \(locks: [^ )]*?\.(\w+)\)`), rule: "lock-leaked", fields: []int{1}},
+	{re: regexp.MustCompile(`^return with unexpected locks held`), rule: "lock-leaked"},
+	{
+		re:       regexp.MustCompile(`^may require checklocks annotation for (\w+)`),
+		rule:     "missing-annotation",
+		fields:   []int{1},
+		annotate: func(m []string) string { return "+checklocks:" + m[1] },
+	},
+	{re: regexp.MustCompile(`^call to \S+ acquires (\S+) while holding (\S+), violating \+lockorder:`), rule: "lock-order", fields: []int{1, 2}, classes: true},
+	{re: regexp.MustCompile(`^(\S+) acquired while holding (\S+), violating \+lockorder:`), rule: "lock-order", fields: []int{1, 2}, classes: true},
+	{re: regexp.MustCompile(`^\+checklocksignore function \S+ does not assert ([^,\s]+)`), rule: "missing-assertion", fields: []int{1}},
+	{re: regexp.MustCompile(`^\+checklocksforce:(\S+) covers `), rule: "force-site", fields: []int{1}},
+	{re: regexp.MustCompile(`^\+checklocksforce:(\S+) is not followed by`), rule: "force-unused", fields: []int{1}},
+	{re: regexp.MustCompile(`^\+checklocksforce:(\S+) leaks past`), rule: "force-leak", fields: []int{1}},
+	{re: regexp.MustCompile(`^\+lockorder`), rule: "invalid-annotation"},
+}
+
+// paramRE matches checklocks' rendering of a receiver or parameter field,
+// as in "&({param:pr}.mu)".
+var paramRE = regexp.MustCompile(`\{param:(\w+)\}\.(\w+)\)?$`)
+
+// lockPath turns a checklocks lock expression into an annotation path,
+// falling back to the bare field name.
+func lockPath(expr, field string) string {
+	if m := paramRE.FindStringSubmatch(expr); m != nil {
+		return m[1] + "." + m[2]
+	}
+	return field
+}
+
+// Classify converts d into a Finding. Positions are made relative to root.
+func Classify(root string, d vetrun.Diagnostic) Finding {
+	f, _ := classify(root, d)
+	return f
+}
+
+// classify is Classify, also reporting whether the message leaves the
+// field to be read from the source.
+func classify(root string, d vetrun.Diagnostic) (f Finding, atSource bool) {
+	f = Finding{Analyzer: d.Analyzer, Rule: "other", Message: d.Message}
+	f.File, f.Line, f.Column = splitPosn(root, d.Posn)
+	for _, p := range patterns {
+		m := p.re.FindStringSubmatch(d.Message)
+		if m == nil {
+			continue
+		}
+		f.Rule = p.rule
+		for _, i := range p.fields {
+			field := m[i]
+			if !p.classes {
+				field = field[strings.LastIndexByte(field, '.')+1:]
+			}
+			f.Fields = append(f.Fields, field)
+		}
+		if p.annotate != nil {
+			f.Annotation = p.annotate(m)
+		}
+		atSource = p.atSource
+		break
+	}
+	return f, atSource
+}
+
+// ClassifyAll classifies diags, resolves the function enclosing each one
+// from the source under root, and sorts the findings by position. For
+// messages that name no field, such as "illegal use of atomic-only field",
+// the field is the one accessed at the diagnostic's position.
+//
+// checklocks reports a function that falls off its end holding a lock
+// without a position. When forceaudit reports a single +checklocksforce of
+// that lock leaking, the finding is placed at that force, whose function it
+// belongs to.
+func ClassifyAll(root string, diags []vetrun.Diagnostic) []Finding {
+	fset := token.NewFileSet()
+	files := make(map[string]*ast.File)
+	fs := make([]Finding, 0, len(diags))
+	for _, d := range diags {
+		finding, atSource := classify(root, d)
+		fs = append(fs, finding)
+		i := len(fs) - 1
+		if fs[i].File == "" {
+			continue
+		}
+		name := filepath.FromSlash(fs[i].File)
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(root, name)
+		}
+		f, ok := files[name]
+		if !ok {
+			// An unreadable file leaves the function unknown, which only
+			// makes the baseline key coarser.
+			f, _ = parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+			files[name] = f
+		}
+		if f == nil {
+			continue
+		}
+		fs[i].Function = enclosing(fset, f, fs[i].Line)
+		if atSource {
+			if field := accessedField(fset, f, fs[i].Line, fs[i].Column); field != "" {
+				fs[i].Fields = []string{field}
+			}
+		}
+	}
+	placeLeaks(fs)
+	slices.SortStableFunc(fs, func(a, b Finding) int {
+		return cmp.Or(
+			cmp.Compare(a.File, b.File),
+			cmp.Compare(a.Line, b.Line),
+			cmp.Compare(a.Column, b.Column),
+			cmp.Compare(a.Analyzer, b.Analyzer),
+			cmp.Compare(a.Message, b.Message),
+		)
+	})
+	return fs
+}
+
+// enclosing names the top-level declaration of f spanning line: a function
+// or method, or a type.
+func enclosing(fset *token.FileSet, f *ast.File, line int) string {
+	for _, d := range f.Decls {
+		if fset.Position(d.Pos()).Line > line || fset.Position(d.End()).Line < line {
+			continue
+		}
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			return annotation.FuncName(d)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if ok && fset.Position(ts.Pos()).Line <= line && fset.Position(ts.End()).Line >= line {
+					return ts.Name.Name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// accessedField names the field selected at line and col of f, where
+// checklocks positions atomic misuse: the selector of a direct access, or
+// the first argument, as in atomic.StoreInt32(&pr.v, 1), of a call whose
+// parenthesis is there. It returns "" if there is neither.
+func accessedField(fset *token.FileSet, f *ast.File, line, col int) string {
+	tf := fset.File(f.Pos())
+	if line < 1 || line > tf.LineCount() || col < 1 {
+		return ""
+	}
+	pos := tf.LineStart(line) + token.Pos(col-1)
+	field := ""
+	ast.Inspect(f, func(n ast.Node) bool {
+		if field != "" || n == nil || n.Pos() > pos || n.End() <= pos {
+			return field == ""
+		}
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if n.Sel.Pos() == pos {
+				field = n.Sel.Name
+			}
+		case *ast.CallExpr:
+			if n.Lparen != pos || len(n.Args) == 0 {
+				break
+			}
+			arg := n.Args[0]
+			if u, ok := arg.(*ast.UnaryExpr); ok && u.Op == token.AND {
+				arg = u.X
+			}
+			if sel, ok := ast.Unparen(arg).(*ast.SelectorExpr); ok {
+				field = sel.Sel.Name
+			}
+		}
+		return field == ""
+	})
+	return field
+}
+
+// placeLeaks gives each unpositioned lock-leaked finding the position and
+// function of the force-leak finding for the same lock, if there is exactly
+// one.
+func placeLeaks(fs []Finding) {
+	for i := range fs {
+		if fs[i].Rule != "lock-leaked" || fs[i].File != "" || len(fs[i].Fields) == 0 {
+			continue
+		}
+		var force Finding
+		n := 0
+		for _, g := range fs {
+			if g.Rule == "force-leak" && g.File != "" && slices.Equal(g.Fields, fs[i].Fields) {
+				force = g
+				n++
+			}
+		}
+		if n == 1 {
+			fs[i].File, fs[i].Line, fs[i].Column, fs[i].Function = force.File, force.Line, force.Column, force.Function
+		}
+	}
+}
+
+// splitPosn parses "file:line:col" or "file:line". An unpositioned
+// diagnostic ("-") yields zero values.
+func splitPosn(root, posn string) (file string, line, col int) {
+	if posn == "-" || posn == "" {
+		return "", 0, 0
+	}
+	file = posn
+	var nums []int
+	for range 2 {
+		i := strings.LastIndexByte(file, ':')
+		if i < 0 {
+			break
+		}
+		n, err := strconv.Atoi(file[i+1:])
+		if err != nil {
+			break
+		}
+		nums = append([]int{n}, nums...)
+		file = file[:i]
+	}
+	if len(nums) > 0 {
+		line = nums[0]
+	}
+	if len(nums) > 1 {
+		col = nums[1]
+	}
+	if r, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(r, "..") {
+		file = r
+	}
+	return filepath.ToSlash(file), line, col
+}
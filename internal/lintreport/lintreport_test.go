@@ -0,0 +1,265 @@
+package lintreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kakkoyun/checklocks-demo/internal/vetrun"
+)
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		msg        string
+		rule       string
+		fields     []string
+		annotation string
+	}{
+		{
+			msg:        "invalid field access, mu (&({param:pr}.mu)) must be locked when accessing value (locks: no locks held)",
+			rule:       "lock-not-held",
+			fields:     []string{"value", "mu"},
+			annotation: "+checklocks:pr.mu",
+		},
+		{
+			msg:        "must hold pr.mu exclusively (&({param:pr}.mu)) to call setDataLocked, but not held (locks: no locks held)",
+			rule:       "acquire-precondition",
+			fields:     []string{"mu"},
+			annotation: "+checklocks:pr.mu",
+		},
+		{
+			msg:        "must hold pr.rwMu non-exclusively (&({param:pr}.rwMu)) to call readDataRLocked, but not held (locks: no locks held)",
+			rule:       "acquire-precondition",
+			fields:     []string{"rwMu"},
+			annotation: "+checklocksread:pr.rwMu",
+		},
+		{
+			msg:  "illegal use of atomic-only field by *ssa.UnOp instruction",
+			rule: "non-atomic-access",
+		},
+		{
+			msg:    "non-atomic write of field mixedValue, writes must still be atomic with locks held (locks: &({param:pr}.mu) exclusively)",
+			rule:   "non-atomic-access",
+			fields: []string{"mixedValue"},
+		},
+		{
+			msg:  "unexpected call to atomic write function, is a lock missing?",
+			rule: "non-atomic-access",
+		},
+		{
+			msg:    "attempt to acquire pr.acquireReleaseMu (&({param:pr}.acquireReleaseMu)), but already held (locks: &({param:pr}.acquireReleaseMu) exclusively)",
+			rule:   "double-acquire",
+			fields: []string{"acquireReleaseMu"},
+		},
+		{
+			msg:    "attempt to release pr.acquireReleaseMu (&({param:pr}.acquireReleaseMu)), but not held (locks: no locks held)",
+			rule:   "release-not-held",
+			fields: []string{"acquireReleaseMu"},
+		},
+		{
+			msg:    "return with unexpected locks held (locks: &({param:pr}.mu) exclusively)",
+			rule:   "lock-leaked",
+			fields: []string{"mu"},
+		},
+		{
+			msg:        "may require checklocks annotation for mu, used with lock held 100% of the time",
+			rule:       "missing-annotation",
+			fields:     []string{"mu"},
+			annotation: "+checklocks:mu",
+		},
+		{
+			msg:    "ProtectedResource.mu acquired while holding ProtectedResource.rwMu, violating +lockorder:mu<rwMu<acquireReleaseMu",
+			rule:   "lock-order",
+			fields: []string{"ProtectedResource.mu", "ProtectedResource.rwMu"},
+		},
+		{
+			msg:    "call to a.LockMu acquires R.mu while holding R.arMu, violating +lockorder:mu<arMu",
+			rule:   "lock-order",
+			fields: []string{"R.mu", "R.arMu"},
+		},
+		{
+			msg:    "+checklocksignore function FunctionToIgnore does not assert pr.mu, o.rwMu held at entry (accesses pr.value, o.read)",
+			rule:   "missing-assertion",
+			fields: []string{"mu"},
+		},
+		{
+			msg:    "+checklocksforce:pr.mu covers pr.value, pr.description",
+			rule:   "force-site",
+			fields: []string{"mu"},
+		},
+		{
+			msg:    "+checklocksforce:pr.rwMu is not followed by any access that requires pr.rwMu in R.f",
+			rule:   "force-unused",
+			fields: []string{"rwMu"},
+		},
+		{
+			msg:    "+checklocksforce:pr.mu leaks past the end of ProtectedResource.ForceExample: pr.mu is still considered held there",
+			rule:   "force-leak",
+			fields: []string{"mu"},
+		},
+		{
+			msg:  "+lockorder needs at least two fields, as in +lockorder:a<b",
+			rule: "invalid-annotation",
+		},
+		{
+			msg:  "something new",
+			rule: "other",
+		},
+	} {
+		f := Classify("/repo", vetrun.Diagnostic{Analyzer: "checklocks", Posn: "/repo/pkg/a.go:3:5", Message: tc.msg})
+		if f.Rule != tc.rule || !slices.Equal(f.Fields, tc.fields) || f.Annotation != tc.annotation {
+			t.Errorf("Classify(%q) = rule %q, fields %q, annotation %q; want %q, %q, %q",
+				tc.msg, f.Rule, f.Fields, f.Annotation, tc.rule, tc.fields, tc.annotation)
+		}
+		if f.File != "pkg/a.go" || f.Line != 3 || f.Column != 5 {
+			t.Errorf("Classify position = %s:%d:%d, want pkg/a.go:3:5", f.File, f.Line, f.Column)
+		}
+	}
+}
+
+func TestSplitPosn(t *testing.T) {
+	for _, tc := range []struct {
+		posn      string
+		file      string
+		line, col int
+	}{
+		{"/repo/a.go:10:2", "a.go", 10, 2},
+		{"/repo/a.go:10", "a.go", 10, 0},
+		{"/elsewhere/a.go:1:1", "/elsewhere/a.go", 1, 1},
+		{"-", "", 0, 0},
+	} {
+		file, line, col := splitPosn("/repo", tc.posn)
+		if file != tc.file || line != tc.line || col != tc.col {
+			t.Errorf("splitPosn(%q) = %q, %d, %d; want %q, %d, %d", tc.posn, file, line, col, tc.file, tc.line, tc.col)
+		}
+	}
+}
+
+func TestClassifyAllSorts(t *testing.T) {
+	fs := ClassifyAll("/repo", []vetrun.Diagnostic{
+		{Analyzer: "checklocks", Posn: "/repo/b.go:1:1", Message: "x"},
+		{Analyzer: "checklocks", Posn: "/repo/a.go:9:1", Message: "x"},
+		{Analyzer: "checklocks", Posn: "/repo/a.go:2:1", Message: "x"},
+	})
+	var got []string
+	for _, f := range fs {
+		got = append(got, f.File)
+	}
+	if !slices.Equal(got, []string{"a.go", "a.go", "b.go"}) || fs[0].Line != 2 {
+		t.Errorf("ClassifyAll order = %v", fs)
+	}
+}
+
+func TestClassifyAllReadsAtomicFields(t *testing.T) {
+	const src = `package p
+
+import "sync/atomic"
+
+type R struct {
+	// +checkatomic
+	a int32
+}
+
+func (r *R) f() int32 {
+	atomic.StoreInt32(&r.a, 1)
+	r.a = 2
+	return r.a
+}
+`
+	root := t.TempDir()
+	file := filepath.Join(root, "p.go")
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs := ClassifyAll(root, []vetrun.Diagnostic{
+		{Analyzer: "checklocks", Posn: file + ":11:19", Message: "unexpected call to atomic write function, is a lock missing?"},
+		{Analyzer: "checklocks", Posn: file + ":12:4", Message: "illegal use of atomic-only field by *ssa.Store instruction"},
+		{Analyzer: "checklocks", Posn: file + ":13:11", Message: "illegal use of atomic-only field by *ssa.UnOp instruction"},
+		{Analyzer: "checklocks", Posn: file + ":13:2", Message: "illegal use of atomic-only field by *ssa.UnOp instruction"},
+	})
+	var got []string
+	for _, f := range fs {
+		got = append(got, f.Function+" "+strings.Join(f.Fields, ","))
+	}
+	if want := []string{"R.f a", "R.f a", "R.f ", "R.f a"}; !slices.Equal(got, want) {
+		t.Errorf("ClassifyAll = %q, want %q", got, want)
+	}
+}
+
+func TestClassifyAllPlacesLeaks(t *testing.T) {
+	leak := vetrun.Diagnostic{Analyzer: "checklocks", Posn: "-", Message: "return with unexpected locks held (locks: &({param:pr}.mu) exclusively)"}
+	force := func(posn string) vetrun.Diagnostic {
+		return vetrun.Diagnostic{Analyzer: "forceaudit", Posn: posn, Message: "+checklocksforce:pr.mu leaks past the end of R.f: pr.mu is still considered held there"}
+	}
+	fs := ClassifyAll("/repo", []vetrun.Diagnostic{leak, force("/repo/p/a.go:7:2")})
+	if fs[0].Rule != "lock-leaked" || fs[0].File != "p/a.go" || fs[0].Line != 7 {
+		t.Errorf("ClassifyAll = %+v, want the leak placed at the force", fs)
+	}
+
+	// Two candidate forces leave it unplaced.
+	fs = ClassifyAll("/repo", []vetrun.Diagnostic{leak, force("/repo/p/a.go:7:2"), force("/repo/q/b.go:3:2")})
+	if fs[0].Rule != "lock-leaked" || fs[0].File != "" {
+		t.Errorf("ClassifyAll = %+v, want the leak unplaced", fs)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	omitted, err := WriteSARIF(&buf, []Finding{
+		{File: "pkg/resource/resource.go", Line: 70, Column: 5, Analyzer: "checklocks", Rule: "lock-not-held",
+			Message: "m", Fields: []string{"value", "mu"}, Annotation: "+checklocks:pr.mu"},
+		{Analyzer: "checklocks", Rule: "lock-leaked", Message: "return with unexpected locks held"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log: %s", buf.Bytes())
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(Rules) {
+		t.Errorf("driver has %d rules, want %d", len(run.Tool.Driver.Rules), len(Rules))
+	}
+	if len(run.Results) != 1 {
+		t.Fatalf("got %d results, want 1", len(run.Results))
+	}
+	if len(omitted) != 1 || omitted[0].Rule != "lock-leaked" {
+		t.Errorf("omitted = %+v, want the unpositioned leak", omitted)
+	}
+	r := run.Results[0]
+	if run.Tool.Driver.Rules[r.RuleIndex].ID != r.RuleID {
+		t.Errorf("ruleIndex %d does not point at %s", r.RuleIndex, r.RuleID)
+	}
+	loc := r.Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "pkg/resource/resource.go" || loc.Region.StartLine != 70 || loc.Region.StartColumn != 5 {
+		t.Errorf("unexpected location: %+v", loc)
+	}
+	if r.Message.Text != "m (suggested annotation: +checklocks:pr.mu)" || r.Properties.Annotation != "+checklocks:pr.mu" {
+		t.Errorf("unexpected result: %+v", r)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Version  int
+		Findings []Finding
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != SchemaVersion || doc.Findings == nil {
+		t.Errorf("WriteJSON(nil) = %s", buf.Bytes())
+	}
+}
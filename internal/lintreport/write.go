@@ -0,0 +1,166 @@
+package lintreport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SchemaVersion is the version of the JSON document written by WriteJSON.
+const SchemaVersion = 1
+
+// WriteJSON writes findings as {"version": 1, "findings": [...]}, adding
+// "staleBaseline" when baseline entries no longer match.
+func WriteJSON(w io.Writer, findings []Finding, stale []Entry) error {
+	if findings == nil {
+		findings = []Finding{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(struct {
+		Version  int       `json:"version"`
+		Findings []Finding `json:"findings"`
+		Stale    []Entry   `json:"staleBaseline,omitempty"`
+	}{SchemaVersion, findings, stale})
+}
+
+// WriteText writes findings in go vet's "file:line:col: message" form with
+// the rule appended, followed by any stale baseline entries.
+func WriteText(w io.Writer, findings []Finding, stale []Entry) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range findings {
+		posn := "-"
+		if f.File != "" {
+			posn = fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+		}
+		fmt.Fprintf(bw, "%s: %s [%s]\n", posn, f.Message, f.Rule)
+		if f.Annotation != "" {
+			fmt.Fprintf(bw, "\tsuggested annotation: %s\n", f.Annotation)
+		}
+	}
+	for _, e := range stale {
+		fmt.Fprintf(bw, "stale baseline entry, no longer reported: %s\n", e)
+	}
+	return bw.Flush()
+}
+
+// SARIF 2.1.0, restricted to what code-scanning uploads use.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID               string       `json:"id"`
+		ShortDescription sarifMessage `json:"shortDescription"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifResult struct {
+		RuleID     string          `json:"ruleId"`
+		RuleIndex  int             `json:"ruleIndex"`
+		Level      string          `json:"level"`
+		Message    sarifMessage    `json:"message"`
+		Locations  []sarifLocation `json:"locations"`
+		Properties sarifProperties `json:"properties"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           *sarifRegion          `json:"region,omitempty"`
+	}
+	sarifArtifactLocation struct {
+		URI       string `json:"uri"`
+		URIBaseID string `json:"uriBaseId"`
+	}
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+	sarifProperties struct {
+		Analyzer   string   `json:"analyzer"`
+		Fields     []string `json:"fields,omitempty"`
+		Annotation string   `json:"suggestedAnnotation,omitempty"`
+	}
+)
+
+const (
+	sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolURI     = "https://github.com/kakkoyun/checklocks-demo"
+)
+
+// WriteSARIF writes findings as a SARIF 2.1.0 log with a single run. Files
+// are relative to %SRCROOT%, the repository root of the upload. Code
+// scanning rejects results without a location, so findings without a file
+// are left out and returned for the caller to report elsewhere. Stale
+// baseline entries have no place in a code-scanning upload and are left to
+// the JSON and text formats.
+func WriteSARIF(w io.Writer, findings []Finding) (omitted []Finding, err error) {
+	driver := sarifDriver{Name: "lockvet", InformationURI: toolURI}
+	index := make(map[string]int, len(Rules))
+	for i, r := range Rules {
+		index[r.ID] = i
+		driver.Rules = append(driver.Rules, sarifRule{ID: r.ID, ShortDescription: sarifMessage{r.Doc}})
+	}
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		if f.File == "" {
+			omitted = append(omitted, f)
+			continue
+		}
+		text := f.Message
+		if f.Annotation != "" {
+			text += " (suggested annotation: " + f.Annotation + ")"
+		}
+		level := "error"
+		switch {
+		case f.Rule == "missing-annotation":
+			level = "warning" // A suggestion, not a violation.
+		case Inventory(f.Rule):
+			level = "note"
+		}
+		r := sarifResult{
+			RuleID:    f.Rule,
+			RuleIndex: index[f.Rule],
+			Level:     level,
+			Message:   sarifMessage{text},
+			Properties: sarifProperties{
+				Analyzer:   f.Analyzer,
+				Fields:     f.Fields,
+				Annotation: f.Annotation,
+			},
+		}
+		loc := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: f.File, URIBaseID: "%SRCROOT%"}}
+		if f.Line > 0 {
+			loc.Region = &sarifRegion{StartLine: f.Line, StartColumn: f.Column}
+		}
+		r.Locations = []sarifLocation{{PhysicalLocation: loc}}
+		results = append(results, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return omitted, enc.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
@@ -0,0 +1,31 @@
+//go:build !debug
+
+// Package lockassert mirrors checklocks function annotations with runtime
+// checks, catching violations on paths the analyzer cannot see, such as
+// calls through interfaces or reflection.
+//
+// The checks are only compiled in with the debug build tag
+// (go build -tags debug, as used by make test). Without it every function
+// here is empty and inlined away. Like go-mutexasserts, a failed check
+// prints a stack trace and exits the process so it cannot be recovered.
+//
+// A runtime check can only tell whether a lock is held by some goroutine,
+// not by the caller, so the checks are limited to "held" conditions.
+package lockassert
+
+import "sync"
+
+// Enabled reports whether the checks are compiled in.
+const Enabled = false
+
+// Held asserts that m is locked. It mirrors +checklocks:m, the entry
+// condition of +checklocksrelease:m and the exit condition of functions
+// annotated +checklocksacquire:m.
+func Held(m *sync.Mutex) {}
+
+// WHeld asserts that rw is write-locked.
+func WHeld(rw *sync.RWMutex) {}
+
+// RHeld asserts that rw is read-locked or write-locked, as functions
+// annotated +checklocksread:rw require.
+func RHeld(rw *sync.RWMutex) {}
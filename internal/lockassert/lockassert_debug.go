@@ -0,0 +1,114 @@
+//go:build debug
+
+package lockassert
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Enabled reports whether the checks are compiled in.
+const Enabled = true
+
+// mutexLocked is the locked bit of a sync.Mutex state word.
+const mutexLocked = 1
+
+// Offsets of the words read by the checks. They are resolved from the
+// sync types' layouts once, so the checks themselves are plain atomic loads
+// and stay quiet under the race detector (go-mutexasserts reads the same
+// words non-atomically through reflect).
+var (
+	mutexStateOffset = fieldOffset(reflect.TypeFor[sync.Mutex](),
+		[]string{"mu", "state"}, // Go 1.24 and later: the state is in internal/sync.
+		[]string{"state"})
+	rwWriterOffset      = fieldOffset(reflect.TypeFor[sync.RWMutex](), []string{"w"})
+	rwReaderCountOffset = fieldOffset(reflect.TypeFor[sync.RWMutex](),
+		[]string{"readerCount", "v"}, // Go 1.20 and later: an atomic.Int32.
+		[]string{"readerCount"})
+)
+
+// exit is replaceable so the failure path can be exercised in tests.
+var exit = os.Exit
+
+// fieldOffset returns the offset of the field reached by the first of
+// layouts, the paths of field names for each known sync layout, whose every
+// name resolves. It panics if none does: the layout has changed in a way the
+// checks do not know, and reading any other word would make them check the
+// wrong thing.
+func fieldOffset(t reflect.Type, layouts ...[]string) uintptr {
+	for _, path := range layouts {
+		if off, ok := pathOffset(t, path); ok {
+			return off
+		}
+	}
+	panic(fmt.Sprintf("lockassert: %v has none of the field paths %v; update the offsets for this sync layout", t, layouts))
+}
+
+// pathOffset returns the offset of the field reached by path from t, and
+// whether every name in it resolves.
+func pathOffset(t reflect.Type, path []string) (uintptr, bool) {
+	var off uintptr
+	for _, name := range path {
+		if t.Kind() != reflect.Struct {
+			return 0, false
+		}
+		f, ok := t.FieldByName(name)
+		if !ok {
+			return 0, false
+		}
+		off += f.Offset
+		t = f.Type
+	}
+	return off, len(path) > 0
+}
+
+func load(p unsafe.Pointer, off uintptr) int32 {
+	return atomic.LoadInt32((*int32)(unsafe.Add(p, off)))
+}
+
+func mutexHeld(m *sync.Mutex) bool {
+	return load(unsafe.Pointer(m), mutexStateOffset)&mutexLocked != 0
+}
+
+func rwWriteHeld(rw *sync.RWMutex) bool {
+	return mutexHeld((*sync.Mutex)(unsafe.Add(unsafe.Pointer(rw), rwWriterOffset)))
+}
+
+func rwReadHeld(rw *sync.RWMutex) bool {
+	return load(unsafe.Pointer(rw), rwReaderCountOffset) > 0
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "lockassert: "+format+"\n", args...)
+	debug.PrintStack()
+	exit(1)
+}
+
+// Held asserts that m is locked. It mirrors +checklocks:m, the entry
+// condition of +checklocksrelease:m and the exit condition of functions
+// annotated +checklocksacquire:m.
+func Held(m *sync.Mutex) {
+	if !mutexHeld(m) {
+		fail("mutex %p must be held", m)
+	}
+}
+
+// WHeld asserts that rw is write-locked.
+func WHeld(rw *sync.RWMutex) {
+	if !rwWriteHeld(rw) {
+		fail("rwmutex %p must be write-held", rw)
+	}
+}
+
+// RHeld asserts that rw is read-locked or write-locked, as functions
+// annotated +checklocksread:rw require.
+func RHeld(rw *sync.RWMutex) {
+	if !rwReadHeld(rw) && !rwWriteHeld(rw) {
+		fail("rwmutex %p must be held at least for reading", rw)
+	}
+}
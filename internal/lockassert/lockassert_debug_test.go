@@ -0,0 +1,63 @@
+//go:build debug
+
+package lockassert
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// TestViolationExits swaps out exit so a failed assertion can be observed
+// without ending the test process.
+func TestViolationExits(t *testing.T) {
+	defer func(orig func(int)) { exit = orig }(exit)
+	exit = func(code int) { panic(code) }
+
+	var mu sync.Mutex
+	var rw sync.RWMutex
+	if !exits(func() { Held(&mu) }) {
+		t.Error("Held on an unlocked mutex did not fail")
+	}
+	if !exits(func() { WHeld(&rw) }) {
+		t.Error("WHeld on an unlocked rwmutex did not fail")
+	}
+	if !exits(func() { RHeld(&rw) }) {
+		t.Error("RHeld on an unlocked rwmutex did not fail")
+	}
+
+	rw.RLock()
+	if !exits(func() { WHeld(&rw) }) {
+		t.Error("WHeld on a read-locked rwmutex did not fail")
+	}
+	rw.RUnlock()
+}
+
+// exits reports whether assert called exit, which the tests replace with a
+// panic.
+func exits(assert func()) (exited bool) {
+	defer func() { exited = recover() != nil }()
+	assert()
+	return false
+}
+
+func TestFieldOffset(t *testing.T) {
+	typ := reflect.TypeFor[sync.Mutex]()
+	if got := fieldOffset(typ, []string{"noSuchField"}, []string{"mu", "state"}); got != mutexStateOffset {
+		t.Errorf("fieldOffset skipping an unknown layout = %d, want %d", got, mutexStateOffset)
+	}
+	for _, layouts := range [][][]string{
+		{{"noSuchField"}},
+		{{"mu", "noSuchField"}}, // A partial match must not count.
+		{{"noSuchField", "state"}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("fieldOffset(%v) resolved a path that does not match the layout", layouts)
+				}
+			}()
+			fieldOffset(typ, layouts...)
+		}()
+	}
+}
@@ -0,0 +1,25 @@
+package lockassert
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestHeldPasses checks that satisfied assertions return normally in both
+// debug and release builds.
+func TestHeldPasses(t *testing.T) {
+	var mu sync.Mutex
+	mu.Lock()
+	Held(&mu)
+	mu.Unlock()
+
+	var rw sync.RWMutex
+	rw.RLock()
+	RHeld(&rw)
+	rw.RUnlock()
+
+	rw.Lock()
+	WHeld(&rw)
+	RHeld(&rw) // A write lock satisfies a read requirement.
+	rw.Unlock()
+}
@@ -0,0 +1,494 @@
+// Package lockcover measures how much of a codebase's mutex-protected state
+// carries checklocks annotations.
+//
+// For every struct with a sync.Mutex or sync.RWMutex field it classifies the
+// other fields as annotated (+checklocks, +checklocksread or +checkatomic),
+// ignored (+checklocksignore), deliberately unguarded (a comment saying so,
+// like "This field is not guarded by mu"), synchronized (a channel, a sync
+// or sync/atomic type, or a struct with its own mutex), or unannotated. It
+// also lists the functions that use the +checklocksignore and
+// +checklocksforce escape hatches. Everything is syntactic: annotations are
+// comments, so no type checking is needed.
+package lockcover
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/kakkoyun/checklocks-demo/internal/annotation"
+)
+
+// SchemaVersion is the version of the JSON document written by WriteJSON.
+const SchemaVersion = 1
+
+// Field statuses.
+const (
+	Mutex        = "mutex"        // The lock itself.
+	Annotated    = "annotated"    // +checklocks, +checklocksread or +checkatomic.
+	Ignored      = "ignored"      // +checklocksignore.
+	Unguarded    = "unguarded"    // Documented as deliberately not guarded.
+	Synchronized = "synchronized" // Safe by type: channel, sync, sync/atomic or self-locking struct.
+	Unannotated  = "unannotated"
+)
+
+// Report is the coverage of a set of packages. Its JSON form is the schema
+// written by WriteJSON; fields are only ever added to it.
+type Report struct {
+	Version  int       `json:"version"`
+	Packages []Package `json:"packages"`
+	Summary
+}
+
+// Summary counts fields by status. Coverage is the share of fields that
+// need an annotation and have one: annotated / (annotated + ignored +
+// unannotated). Mutexes, synchronized fields and documented unguarded
+// fields need none. With nothing to cover, Coverage is 1.
+type Summary struct {
+	Structs       int     `json:"structs"`
+	Annotated     int     `json:"annotated"`
+	Ignored       int     `json:"ignored"`
+	Unguarded     int     `json:"unguarded"`
+	Synchronized  int     `json:"synchronized"`
+	Unannotated   int     `json:"unannotated"`
+	EscapeHatches int     `json:"escapeHatches"`
+	Coverage      float64 `json:"coverage"`
+}
+
+func (s *Summary) add(o Summary) {
+	s.Structs += o.Structs
+	s.Annotated += o.Annotated
+	s.Ignored += o.Ignored
+	s.Unguarded += o.Unguarded
+	s.Synchronized += o.Synchronized
+	s.Unannotated += o.Unannotated
+	s.EscapeHatches += o.EscapeHatches
+	s.Coverage = coverage(s.Annotated, s.Annotated+s.Ignored+s.Unannotated)
+}
+
+func (s *Summary) count(status string) {
+	switch status {
+	case Annotated:
+		s.Annotated++
+	case Ignored:
+		s.Ignored++
+	case Unguarded:
+		s.Unguarded++
+	case Synchronized:
+		s.Synchronized++
+	case Unannotated:
+		s.Unannotated++
+	}
+	s.Coverage = coverage(s.Annotated, s.Annotated+s.Ignored+s.Unannotated)
+}
+
+func coverage(n, d int) float64 {
+	if d == 0 {
+		return 1
+	}
+	return float64(n) / float64(d)
+}
+
+// Package is the coverage of one package.
+type Package struct {
+	Path          string        `json:"path"`
+	Dir           string        `json:"dir"` // Slash-separated, relative to the report root.
+	Structs       []Struct      `json:"structs"`
+	EscapeHatches []EscapeHatch `json:"escapeHatches"`
+	Summary
+}
+
+// Struct is the coverage of one struct with a mutex field.
+type Struct struct {
+	Name   string  `json:"name"`
+	File   string  `json:"file"`
+	Line   int     `json:"line"`
+	Fields []Field `json:"fields"`
+	Summary
+}
+
+// Field is one field of a Struct. Fields declared together, as in "a, b
+// int", are listed separately.
+type Field struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	// Annotations are the field's checklocks annotations, e.g.
+	// "+checklocks:mu".
+	Annotations []string `json:"annotations,omitempty"`
+	// Note is the comment that documents an unguarded field.
+	Note string `json:"note,omitempty"`
+}
+
+// EscapeHatch is a function that opts out of checking.
+type EscapeHatch struct {
+	// Function is "Type.Method" for methods.
+	Function string `json:"function"`
+	// Receiver is the method's receiver type, if any.
+	Receiver   string `json:"receiver,omitempty"`
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Annotation string `json:"annotation"` // +checklocksignore or +checklocksforce.
+	// Lock is the lock a +checklocksforce asserts, e.g. "pr.mu".
+	Lock string `json:"lock,omitempty"`
+}
+
+// Load reports on the packages matching patterns, resolved in dir, built
+// with tags. Test files are not included. Paths in the report are relative
+// to dir.
+func Load(dir, tags string, patterns ...string) (*Report, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedImports | packages.NeedModule,
+		Dir:  dir,
+	}
+	if tags != "" {
+		cfg.BuildFlags = []string{"-tags", tags}
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		return nil, fmt.Errorf("lockcover: errors loading packages")
+	}
+
+	// Field types from other packages of the module, such as a watch.Hub,
+	// need those packages' declarations to tell whether they lock
+	// themselves.
+	var deps []string
+	seen := make(map[string]bool)
+	for _, p := range pkgs {
+		seen[p.PkgPath] = true
+	}
+	for _, p := range pkgs {
+		if p.Module == nil {
+			continue
+		}
+		for path := range p.Imports {
+			if !seen[path] && (path == p.Module.Path || strings.HasPrefix(path, p.Module.Path+"/")) {
+				seen[path] = true
+				deps = append(deps, path)
+			}
+		}
+	}
+	var extra []*packages.Package
+	if len(deps) > 0 {
+		slices.Sort(deps)
+		if extra, err = packages.Load(cfg, deps...); err != nil {
+			return nil, err
+		}
+	}
+
+	var srcs, depSrcs []*Source
+	var fset *token.FileSet
+	for i, list := range [][]*packages.Package{pkgs, extra} {
+		for _, p := range list {
+			if fset == nil {
+				fset = p.Fset
+			}
+			s := &Source{Path: p.PkgPath, Dir: relDir(dir, p), Files: p.Syntax}
+			if i == 0 {
+				srcs = append(srcs, s)
+			} else {
+				depSrcs = append(depSrcs, s)
+			}
+		}
+	}
+	if fset == nil {
+		fset = token.NewFileSet()
+	}
+	return Analyze(fset, dir, srcs, depSrcs), nil
+}
+
+func relDir(root string, p *packages.Package) string {
+	if len(p.GoFiles) == 0 {
+		return ""
+	}
+	d := filepath.Dir(p.GoFiles[0])
+	if rel, err := filepath.Rel(root, d); err == nil && !strings.HasPrefix(rel, "..") {
+		d = rel
+	}
+	return filepath.ToSlash(d)
+}
+
+// Source is the parsed syntax of one package.
+type Source struct {
+	Path  string
+	Dir   string
+	Files []*ast.File
+}
+
+// Analyze reports on srcs. deps are other packages whose struct types may
+// appear as field types in srcs; they are not reported on. Test files are
+// skipped. File names are made relative to root.
+func Analyze(fset *token.FileSet, root string, srcs, deps []*Source) *Report {
+	a := &analyzer{fset: fset, root: root, locking: make(map[string]bool)}
+	for _, s := range slices.Concat(srcs, deps) {
+		for _, f := range a.files(s) {
+			for _, ts := range typeSpecs(f) {
+				if st, ok := ts.Type.(*ast.StructType); ok && a.mutexFields(f, st) > 0 {
+					a.locking[s.Path+"."+ts.Name.Name] = true
+				}
+			}
+		}
+	}
+
+	r := &Report{Version: SchemaVersion, Packages: []Package{}}
+	for _, s := range srcs {
+		p := Package{Path: s.Path, Dir: s.Dir, Structs: []Struct{}, EscapeHatches: []EscapeHatch{}}
+		for _, f := range a.files(s) {
+			for _, ts := range typeSpecs(f) {
+				if st, ok := ts.Type.(*ast.StructType); ok && a.mutexFields(f, st) > 0 {
+					p.Structs = append(p.Structs, a.structCoverage(s.Path, f, ts, st))
+				}
+			}
+			p.EscapeHatches = append(p.EscapeHatches, a.escapeHatches(f)...)
+		}
+		slices.SortFunc(p.Structs, func(a, b Struct) int {
+			return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+		})
+		slices.SortFunc(p.EscapeHatches, func(a, b EscapeHatch) int {
+			return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+		})
+		p.Coverage = 1
+		for i := range p.Structs {
+			st := &p.Structs[i]
+			for _, e := range p.EscapeHatches {
+				if e.Receiver == st.Name {
+					st.Summary.EscapeHatches++
+				}
+			}
+			p.add(st.Summary)
+		}
+		// Functions that are not methods of a struct with a mutex count
+		// towards the package only.
+		p.Summary.EscapeHatches = len(p.EscapeHatches)
+		r.Packages = append(r.Packages, p)
+	}
+	slices.SortFunc(r.Packages, func(a, b Package) int { return cmp.Compare(a.Path, b.Path) })
+	r.Coverage = 1
+	for _, p := range r.Packages {
+		r.add(p.Summary)
+	}
+	return r
+}
+
+type analyzer struct {
+	fset *token.FileSet
+	root string
+	// locking holds the "pkgpath.Type" names of structs with a mutex field.
+	locking map[string]bool
+}
+
+// files returns the non-test files of s.
+func (a *analyzer) files(s *Source) []*ast.File {
+	var out []*ast.File
+	for _, f := range s.Files {
+		if !strings.HasSuffix(a.fset.Position(f.Pos()).Filename, "_test.go") {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func (a *analyzer) position(pos token.Pos) (string, int) {
+	p := a.fset.Position(pos)
+	file := p.Filename
+	if rel, err := filepath.Rel(a.root, file); err == nil && !strings.HasPrefix(rel, "..") {
+		file = rel
+	}
+	return filepath.ToSlash(file), p.Line
+}
+
+func typeSpecs(f *ast.File) []*ast.TypeSpec {
+	var out []*ast.TypeSpec
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			out = append(out, spec.(*ast.TypeSpec))
+		}
+	}
+	return out
+}
+
+// imports maps the names f uses for its imports to their paths.
+func imports(f *ast.File) map[string]string {
+	m := make(map[string]string)
+	for _, is := range f.Imports {
+		path, _ := strconv.Unquote(is.Path.Value)
+		name := path[strings.LastIndex(path, "/")+1:]
+		if is.Name != nil {
+			name = is.Name.Name
+		}
+		m[name] = path
+	}
+	return m
+}
+
+// typeName returns the package path and name of a named type expression,
+// looking through pointers and type arguments; pkg is "" for types of the
+// current package.
+func typeName(imps map[string]string, x ast.Expr) (pkg, name string, ok bool) {
+	for {
+		switch t := x.(type) {
+		case *ast.StarExpr:
+			x = t.X
+		case *ast.IndexExpr:
+			x = t.X
+		case *ast.IndexListExpr:
+			x = t.X
+		case *ast.ParenExpr:
+			x = t.X
+		case *ast.Ident:
+			return "", t.Name, true
+		case *ast.SelectorExpr:
+			id, ok := t.X.(*ast.Ident)
+			if !ok || imps[id.Name] == "" {
+				return "", "", false
+			}
+			return imps[id.Name], t.Sel.Name, true
+		default:
+			return "", "", false
+		}
+	}
+}
+
+func isMutexExpr(imps map[string]string, x ast.Expr) bool {
+	if _, ok := x.(*ast.StarExpr); ok {
+		return false
+	}
+	pkg, name, ok := typeName(imps, x)
+	return ok && annotation.IsMutexName(pkg, name)
+}
+
+func (a *analyzer) mutexFields(f *ast.File, st *ast.StructType) int {
+	imps := imports(f)
+	n := 0
+	for _, fld := range st.Fields.List {
+		if isMutexExpr(imps, fld.Type) {
+			n += max(len(fld.Names), 1)
+		}
+	}
+	return n
+}
+
+// synchronized reports whether a field of type x, declared in package
+// pkgPath, is safe to share without a lock of its owner.
+func (a *analyzer) synchronized(pkgPath string, imps map[string]string, x ast.Expr) bool {
+	if _, ok := x.(*ast.ChanType); ok {
+		return true
+	}
+	pkg, name, ok := typeName(imps, x)
+	if !ok {
+		return false
+	}
+	if pkg == "sync" || pkg == "sync/atomic" {
+		return true
+	}
+	if pkg == "" {
+		pkg = pkgPath
+	}
+	return a.locking[pkg+"."+name]
+}
+
+// unguardedNote matches comments documenting that a field needs no lock.
+var unguardedNote = regexp.MustCompile(`(?i)\b(not guarded|unguarded|immutable|read-only)\b`)
+
+func (a *analyzer) structCoverage(pkgPath string, f *ast.File, ts *ast.TypeSpec, st *ast.StructType) Struct {
+	imps := imports(f)
+	file, line := a.position(ts.Pos())
+	s := Struct{Name: ts.Name.Name, File: file, Line: line, Fields: []Field{}, Summary: Summary{Structs: 1, Coverage: 1}}
+	for _, fld := range st.Fields.List {
+		anns := annotations(fld.Doc, fld.Comment)
+		status, note := Unannotated, ""
+		switch {
+		case isMutexExpr(imps, fld.Type):
+			status = Mutex
+		case slices.Contains(anns, "+checklocksignore"):
+			status = Ignored
+		case len(anns) > 0:
+			status = Annotated
+		case a.synchronized(pkgPath, imps, fld.Type):
+			status = Synchronized
+		default:
+			for _, cg := range []*ast.CommentGroup{fld.Doc, fld.Comment} {
+				if text := strings.TrimSpace(cg.Text()); unguardedNote.MatchString(text) {
+					status, note = Unguarded, text
+					break
+				}
+			}
+		}
+		typ := types.ExprString(fld.Type)
+		names := fld.Names
+		if len(names) == 0 { // Embedded.
+			_, name, _ := typeName(imps, fld.Type)
+			names = []*ast.Ident{{Name: name, NamePos: fld.Type.Pos()}}
+		}
+		for _, n := range names {
+			_, l := a.position(n.Pos())
+			s.Fields = append(s.Fields, Field{Name: n.Name, Type: typ, Line: l, Status: status, Annotations: anns, Note: note})
+			s.count(status)
+		}
+	}
+	return s
+}
+
+// annotations returns the checklocks annotations in a field's comments.
+func annotations(groups ...*ast.CommentGroup) []string {
+	var out []string
+	for _, ann := range annotation.List(groups...) {
+		if strings.HasPrefix(ann, "+checklocks") || ann == "+checkatomic" {
+			out = append(out, ann)
+		}
+	}
+	return out
+}
+
+// escapeHatches returns the +checklocksignore functions of f and the
+// +checklocksforce comments in function bodies.
+func (a *analyzer) escapeHatches(f *ast.File) []EscapeHatch {
+	var out []EscapeHatch
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		name, recv := fd.Name.Name, ""
+		if fd.Recv != nil && len(fd.Recv.List) > 0 {
+			recv = annotation.RecvName(fd.Recv.List[0].Type)
+			name = annotation.FuncName(fd)
+		}
+		if annotation.Has(fd.Doc, "+checklocksignore") {
+			file, line := a.position(fd.Name.Pos())
+			out = append(out, EscapeHatch{Function: name, Receiver: recv, File: file, Line: line, Annotation: "+checklocksignore"})
+		}
+		for _, cg := range f.Comments {
+			if cg.Pos() < fd.Pos() || cg.End() > fd.End() || cg == fd.Doc {
+				continue
+			}
+			for _, c := range cg.List {
+				ann, ok := annotation.Parse(c.Text)
+				if !ok || !strings.HasPrefix(ann, "+checklocksforce") {
+					continue
+				}
+				file, line := a.position(c.Pos())
+				_, lock, _ := strings.Cut(ann, ":")
+				out = append(out, EscapeHatch{Function: name, Receiver: recv, File: file, Line: line, Annotation: "+checklocksforce", Lock: lock})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,174 @@
+package lockcover
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const src = `package p
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"example.com/m/hub"
+)
+
+type R struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	value int
+	// +checkatomic
+	// +checklocks:mu
+	mixed int32
+
+	id string // This field is not guarded by mu
+
+	// +checklocksignore
+	racy   int
+	a, b   int
+	n      atomic.Int64
+	ch     chan int
+	h      hub.Hub
+	inner  *inner
+	plain  *plain
+}
+
+type inner struct {
+	mu sync.RWMutex
+}
+
+type plain struct{ x int }
+
+// +checklocksignore
+func (r *R) ignored() { r.a = 1 }
+
+func (r *R) forced() {
+	_ = r.value // +checklocksforce: r.mu
+}
+
+// Not an escape hatch: mentions +checklocksignore in prose.
+func helper() {}
+`
+
+const hubSrc = `package hub
+
+import "sync"
+
+type Hub struct{ mu sync.Mutex }
+`
+
+func parse(t *testing.T, fset *token.FileSet, name, src string) *ast.File {
+	t.Helper()
+	f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func analyze(t *testing.T) *Report {
+	fset := token.NewFileSet()
+	p := &Source{Path: "example.com/m/p", Dir: "p", Files: []*ast.File{parse(t, fset, "/m/p/p.go", src)}}
+	hub := &Source{Path: "example.com/m/hub", Dir: "hub", Files: []*ast.File{parse(t, fset, "/m/hub/hub.go", hubSrc)}}
+	return Analyze(fset, "/m", []*Source{p}, []*Source{hub})
+}
+
+func TestAnalyze(t *testing.T) {
+	r := analyze(t)
+	if len(r.Packages) != 1 || len(r.Packages[0].Structs) != 2 {
+		t.Fatalf("got %+v, want one package with structs R and inner", r.Packages)
+	}
+	s := r.Packages[0].Structs[0]
+	if s.Name != "R" || s.File != "p/p.go" || s.Line != 10 {
+		t.Errorf("struct = %s at %s:%d, want R at p/p.go:10", s.Name, s.File, s.Line)
+	}
+	want := map[string]string{
+		"mu":    Mutex,
+		"value": Annotated,
+		"mixed": Annotated,
+		"id":    Unguarded,
+		"racy":  Ignored,
+		"a":     Unannotated,
+		"b":     Unannotated,
+		"n":     Synchronized,
+		"ch":    Synchronized,
+		"h":     Synchronized,
+		"inner": Synchronized,
+		"plain": Unannotated,
+	}
+	if len(s.Fields) != len(want) {
+		t.Errorf("got %d fields, want %d", len(s.Fields), len(want))
+	}
+	for _, f := range s.Fields {
+		if f.Status != want[f.Name] {
+			t.Errorf("field %s: status %s, want %s", f.Name, f.Status, want[f.Name])
+		}
+		if f.Name == "mixed" && strings.Join(f.Annotations, " ") != "+checkatomic +checklocks:mu" {
+			t.Errorf("mixed annotations = %q", f.Annotations)
+		}
+		if f.Name == "id" && f.Note != "This field is not guarded by mu" {
+			t.Errorf("id note = %q", f.Note)
+		}
+	}
+	if s.Annotated != 2 || s.Ignored != 1 || s.Unannotated != 3 || s.EscapeHatches != 2 {
+		t.Errorf("struct summary = %+v", s.Summary)
+	}
+	if s.Coverage != 2.0/6 {
+		t.Errorf("coverage = %v, want %v", s.Coverage, 2.0/6)
+	}
+	if r.Structs != 2 || r.Coverage != s.Coverage {
+		t.Errorf("report summary = %+v", r.Summary)
+	}
+}
+
+func TestEscapeHatches(t *testing.T) {
+	hs := analyze(t).Packages[0].EscapeHatches
+	if len(hs) != 2 {
+		t.Fatalf("got escape hatches %+v, want 2", hs)
+	}
+	if h := hs[0]; h.Function != "R.ignored" || h.Receiver != "R" || h.Annotation != "+checklocksignore" || h.Line != 37 {
+		t.Errorf("first escape hatch = %+v", h)
+	}
+	if h := hs[1]; h.Function != "R.forced" || h.Annotation != "+checklocksforce" || h.Lock != "r.mu" || h.Line != 40 {
+		t.Errorf("second escape hatch = %+v", h)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	r := analyze(t)
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	var got Report
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != SchemaVersion || got.Annotated != r.Annotated || len(got.Packages[0].Structs[0].Fields) != 12 {
+		t.Errorf("JSON round trip = %+v", got)
+	}
+
+	buf.Reset()
+	if err := WriteHTML(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"33.3%", `<tr class="unannotated"><td><code>a</code>`, "<code>R.forced</code>", "&#43;checklocksforce:r.mu"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("HTML report does not contain %q", s)
+		}
+	}
+
+	buf.Reset()
+	if err := WriteText(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "p/p.go:40: R.forced uses +checklocksforce:r.mu") {
+		t.Errorf("text report:\n%s", buf.String())
+	}
+}
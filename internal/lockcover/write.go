@@ -0,0 +1,105 @@
+package lockcover
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+)
+
+// WriteJSON writes r as an indented JSON document.
+func WriteJSON(w io.Writer, r *Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r)
+}
+
+// WriteText writes a summary line per struct and package, and the escape
+// hatches, for terminals. Packages with neither are omitted.
+func WriteText(w io.Writer, r *Report) error {
+	bw := bufio.NewWriter(w)
+	for _, p := range r.Packages {
+		if len(p.Structs)+len(p.EscapeHatches) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "%s\t%s\n", p.Path, summaryText(p.Summary))
+		for _, s := range p.Structs {
+			fmt.Fprintf(bw, "  %s:%d: %s\t%s\n", s.File, s.Line, s.Name, summaryText(s.Summary))
+			for _, f := range s.Fields {
+				if f.Status == Unannotated {
+					fmt.Fprintf(bw, "    unannotated: %s %s\n", f.Name, f.Type)
+				}
+			}
+		}
+		for _, e := range p.EscapeHatches {
+			fmt.Fprintf(bw, "  %s:%d: %s uses %s\n", e.File, e.Line, e.Function, hatchText(e))
+		}
+	}
+	fmt.Fprintf(bw, "total\t%s\n", summaryText(r.Summary))
+	return bw.Flush()
+}
+
+func summaryText(s Summary) string {
+	return fmt.Sprintf("%.1f%% (%d annotated, %d ignored, %d unannotated; %d unguarded, %d synchronized; %d escape hatches)",
+		100*s.Coverage, s.Annotated, s.Ignored, s.Unannotated, s.Unguarded, s.Synchronized, s.EscapeHatches)
+}
+
+func hatchText(e EscapeHatch) string {
+	if e.Lock != "" {
+		return e.Annotation + ":" + e.Lock
+	}
+	return e.Annotation
+}
+
+// WriteHTML writes r as a self-contained HTML page.
+func WriteHTML(w io.Writer, r *Report) error {
+	return htmlTemplate.Execute(w, r)
+}
+
+var htmlTemplate = template.Must(template.New("lockcover").Funcs(template.FuncMap{
+	"percent": func(f float64) string { return fmt.Sprintf("%.1f%%", 100*f) },
+	"hatch":   hatchText,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>checklocks annotation coverage</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+code { font-size: 0.95em; }
+.mutex { color: #666; }
+.annotated { background: #e6f4e6; }
+.ignored { background: #fff3cd; }
+.unguarded, .synchronized { background: #eef2f7; }
+.unannotated { background: #f8d7da; }
+</style>
+</head>
+<body>
+<h1>checklocks annotation coverage: {{percent .Coverage}}</h1>
+{{template "summary" .Summary}}
+{{range .Packages}}
+<h2><code>{{.Path}}</code>: {{percent .Coverage}}</h2>
+{{template "summary" .Summary}}
+{{range .Structs}}
+<h3><code>{{.Name}}</code> ({{.File}}:{{.Line}}): {{percent .Coverage}}</h3>
+<table>
+<tr><th>Field</th><th>Type</th><th>Status</th><th>Annotations</th><th>Note</th></tr>
+{{range .Fields}}<tr class="{{.Status}}"><td><code>{{.Name}}</code></td><td><code>{{.Type}}</code></td><td>{{.Status}}</td><td>{{range .Annotations}}<code>{{.}}</code> {{end}}</td><td>{{.Note}}</td></tr>
+{{end}}</table>
+{{end}}
+{{with .EscapeHatches}}
+<h3>Escape hatches</h3>
+<table>
+<tr><th>Function</th><th>Annotation</th><th>Location</th></tr>
+{{range .}}<tr><td><code>{{.Function}}</code></td><td><code>{{hatch .}}</code></td><td>{{.File}}:{{.Line}}</td></tr>
+{{end}}</table>
+{{end}}
+{{end}}
+</body>
+</html>
+{{define "summary"}}<p>{{.Structs}} structs with mutexes: {{.Annotated}} annotated, {{.Ignored}} ignored and {{.Unannotated}} unannotated fields; {{.Unguarded}} documented unguarded and {{.Synchronized}} synchronized fields; {{.EscapeHatches}} escape hatches.</p>{{end}}
+`))
@@ -0,0 +1,122 @@
+// Package vetrun runs a go vet tool binary with -json and decodes its
+// findings. It is shared by the vettest harness and lockvet's report mode,
+// both of which run the lock analyzers, checklocks among them, as a vet
+// tool rather than in process.
+package vetrun
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+)
+
+// Diagnostic is one finding from the vet tool's -json output.
+type Diagnostic struct {
+	Analyzer string `json:"-"`
+	Posn     string `json:"posn"`
+	Message  string `json:"message"`
+}
+
+// Vet runs `go vet -vettool=<tool> -json args...` in dir and returns the
+// tool's diagnostics. args holds go vet build flags such as -tags followed
+// by package patterns.
+func Vet(tool, dir string, args ...string) ([]Diagnostic, error) {
+	tool, cleanup, err := uncachedCopy(tool)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	args = append([]string{"vet", "-vettool=" + tool, "-json"}, args...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	out, runErr := cmd.CombinedOutput()
+	diags, err := ParseJSON(out)
+	if err != nil {
+		if runErr != nil {
+			err = runErr
+		}
+		return nil, fmt.Errorf("go vet -vettool=%s: %v\n%s", tool, err, out)
+	}
+	return diags, nil
+}
+
+// uncachedCopy copies tool with a unique trailer appended. go vet caches
+// results under the tool's content hash and, on a hit, replays only what the
+// tool wrote to the vet.stdout file named in its config. Tools built against
+// an x/tools that predates that file print straight to stdout, so a cache hit
+// would report nothing; a copy that hashes differently always runs.
+func uncachedCopy(tool string) (string, func(), error) {
+	data, err := os.ReadFile(tool)
+	if err != nil {
+		return "", nil, err
+	}
+	f, err := os.CreateTemp("", "vettool-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	data = fmt.Appendf(data, "vetrun-%d", time.Now().UnixNano())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		cleanup()
+		return "", nil, err
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if err := os.Chmod(f.Name(), 0o755); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return f.Name(), cleanup, nil
+}
+
+// ParseJSON decodes the unitchecker -json stream: "# package" header lines
+// followed by one object per package, keyed by package then analyzer.
+// Diagnostics are returned in package, then analyzer order; duplicates from
+// test variants of a package are dropped.
+func ParseJSON(out []byte) ([]Diagnostic, error) {
+	var body bytes.Buffer
+	for line := range bytes.Lines(out) {
+		if !bytes.HasPrefix(line, []byte("#")) {
+			body.Write(line)
+		}
+	}
+	seen := make(map[Diagnostic]bool) // Test variants report the same package twice.
+	var diags []Diagnostic
+	dec := json.NewDecoder(&body)
+	for dec.More() {
+		var tree map[string]map[string]json.RawMessage
+		if err := dec.Decode(&tree); err != nil {
+			return nil, err
+		}
+		for _, pkg := range slices.Sorted(maps.Keys(tree)) {
+			analyzers := tree[pkg]
+			for _, name := range slices.Sorted(maps.Keys(analyzers)) {
+				raw := analyzers[name]
+				var ds []Diagnostic
+				if err := json.Unmarshal(raw, &ds); err != nil {
+					var e struct{ Error string }
+					if json.Unmarshal(raw, &e) == nil && e.Error != "" {
+						return nil, fmt.Errorf("%s: %s: %s", pkg, name, e.Error)
+					}
+					return nil, err
+				}
+				for _, d := range ds {
+					d.Analyzer = name
+					if !seen[d] {
+						seen[d] = true
+						diags = append(diags, d)
+					}
+				}
+			}
+		}
+	}
+	return diags, nil
+}
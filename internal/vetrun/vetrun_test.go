@@ -0,0 +1,32 @@
+package vetrun
+
+import "testing"
+
+func TestParseJSON(t *testing.T) {
+	out := []byte(`# a
+# [a]
+{
+	"a": {
+		"checklocks": [
+			{"posn": "/x/a.go:3:2", "message": "m1"},
+			{"posn": "-", "message": "m2"}
+		]
+	}
+}
+# a [a.test]
+{"a [a.test]": {"checklocks": [{"posn": "/x/a.go:3:2", "message": "m1"}]}}
+# b
+{}
+`)
+	diags, err := ParseJSON(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 2 || diags[0].Message != "m1" || diags[1].Posn != "-" || diags[0].Analyzer != "checklocks" {
+		t.Errorf("ParseJSON = %v", diags)
+	}
+
+	if _, err := ParseJSON([]byte(`{"a": {"checklocks": {"error": "boom"}}}`)); err == nil {
+		t.Error("expected analyzer error to be returned")
+	}
+}
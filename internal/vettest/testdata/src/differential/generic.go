@@ -0,0 +1,54 @@
+// Package differential holds a generic type and its non-generic twin with
+// the same deliberate violations, for TestGenericDifferential. Every lock
+// analyzer should report the same findings on both files.
+package differential
+
+import "sync"
+
+// +lockorder:mu<rwMu
+type GenericResource[T any] struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	value T
+
+	rwMu sync.RWMutex
+	// +checklocks:rwMu
+	read T
+
+	unannotated T
+}
+
+func (gr *GenericResource[T]) SetData(v T) {
+	gr.mu.Lock()
+	gr.value = v
+	gr.unannotated = v
+	gr.mu.Unlock()
+}
+
+func (gr *GenericResource[T]) Unannotated() T {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	return gr.unannotated
+}
+
+func (gr *GenericResource[T]) IncorrectSetData(v T) {
+	gr.value = v
+}
+
+func (gr *GenericResource[T]) IncorrectOrder() T {
+	gr.rwMu.RLock()
+	gr.mu.Lock()
+	v := gr.value
+	gr.mu.Unlock()
+	gr.rwMu.RUnlock()
+	return v
+}
+
+// +checklocksignore
+func (gr *GenericResource[T]) Unasserted(v T) {
+	gr.value = v
+}
+
+func (gr *GenericResource[T]) ForceLeak(v T) {
+	gr.value = v // +checklocksforce:gr.mu
+}
@@ -0,0 +1,51 @@
+package differential
+
+import "sync"
+
+// +lockorder:mu<rwMu
+type NonGenericResource struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	value int
+
+	rwMu sync.RWMutex
+	// +checklocks:rwMu
+	read int
+
+	unannotated int
+}
+
+func (ngr *NonGenericResource) SetData(v int) {
+	ngr.mu.Lock()
+	ngr.value = v
+	ngr.unannotated = v
+	ngr.mu.Unlock()
+}
+
+func (ngr *NonGenericResource) Unannotated() int {
+	ngr.mu.Lock()
+	defer ngr.mu.Unlock()
+	return ngr.unannotated
+}
+
+func (ngr *NonGenericResource) IncorrectSetData(v int) {
+	ngr.value = v
+}
+
+func (ngr *NonGenericResource) IncorrectOrder() int {
+	ngr.rwMu.RLock()
+	ngr.mu.Lock()
+	v := ngr.value
+	ngr.mu.Unlock()
+	ngr.rwMu.RUnlock()
+	return v
+}
+
+// +checklocksignore
+func (ngr *NonGenericResource) Unasserted(v int) {
+	ngr.value = v
+}
+
+func (ngr *NonGenericResource) ForceLeak(v int) {
+	ngr.value = v // +checklocksforce:ngr.mu
+}
@@ -0,0 +1,52 @@
+// Package genericresource mirrors pkg/genericresource/generic.go. Older
+// checklocks releases reported "may require checklocks annotation" on the
+// mutex fields of generic types
+// (https://github.com/google/gvisor/issues/11671); the pinned one does not,
+// so the fields carry no want and a regression shows up as a test failure.
+package genericresource
+
+import "sync"
+
+type GenericResource[T any] struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	value T
+
+	rwMu sync.RWMutex
+	// +checklocks:rwMu
+	readGuardedValue T
+
+	acquireReleaseMu sync.Mutex
+	// +checklocks:acquireReleaseMu
+	acquireReleaseValue T
+}
+
+func (gr *GenericResource[T]) SetData(val T) {
+	gr.mu.Lock()
+	gr.value = val
+	gr.mu.Unlock()
+}
+
+func (gr *GenericResource[T]) GetReadGuardedValue() T {
+	gr.rwMu.RLock()
+	defer gr.rwMu.RUnlock()
+	return gr.readGuardedValue
+}
+
+// +checklocksacquire:gr.acquireReleaseMu
+func (gr *GenericResource[T]) AcquireAndSet(v T) {
+	gr.acquireReleaseMu.Lock()
+	gr.acquireReleaseValue = v
+}
+
+// +checklocksrelease:gr.acquireReleaseMu
+func (gr *GenericResource[T]) GetAndRelease() T {
+	v := gr.acquireReleaseValue
+	gr.acquireReleaseMu.Unlock()
+	return v
+}
+
+// Real violations are still found in generic code.
+func (gr *GenericResource[T]) IncorrectSetData(val T) {
+	gr.value = val // want `mu \(.*\) must be locked when accessing value`
+}
@@ -0,0 +1,24 @@
+// Package lockorder exercises the harness itself against this repo's
+// lockvet tool, which the test can always build.
+package lockorder
+
+import "sync"
+
+// +lockorder:a<b
+type T struct {
+	a, b sync.Mutex
+}
+
+func (t *T) InOrder() {
+	t.a.Lock()
+	t.b.Lock()
+	t.b.Unlock()
+	t.a.Unlock()
+}
+
+func (t *T) Inverted() {
+	t.b.Lock()
+	t.a.Lock() // want "T.a acquired while holding T.b"
+	t.a.Unlock()
+	t.b.Unlock()
+}
@@ -0,0 +1,171 @@
+// Package resource mirrors the violations demonstrated in
+// pkg/resource/resource.go, with the checklocks diagnostics each one must
+// produce.
+package resource
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type ProtectedResource struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	value int
+	// +checklocks:mu
+	description string
+
+	rwMu sync.RWMutex
+	// +checklocks:rwMu
+	readGuardedValue int
+
+	// +checkatomic
+	atomicValue int32
+
+	// +checkatomic
+	// +checklocks:mu
+	mixedValue int32
+
+	acquireReleaseMu sync.Mutex
+	// +checklocks:acquireReleaseMu
+	acquireReleaseValue int
+}
+
+// --- Basic Lock Violations ---
+
+func (pr *ProtectedResource) SetData(val int, desc string) {
+	pr.mu.Lock()
+	pr.setDataLocked(val, desc)
+	pr.mu.Unlock()
+}
+
+func (pr *ProtectedResource) IncorrectSetData(val int, desc string) {
+	pr.value = val        // want `mu \(.*\) must be locked when accessing value \(locks: no locks held\)`
+	pr.description = desc // want `mu \(.*\) must be locked when accessing description`
+}
+
+// +checklocks:pr.mu
+func (pr *ProtectedResource) setDataLocked(val int, desc string) {
+	pr.value = val
+	pr.description = desc
+}
+
+func (pr *ProtectedResource) IncorrectSetDataWithHelper(val int, desc string) {
+	pr.setDataLocked(val, desc) // want `must hold pr.mu exclusively .* to call setDataLocked, but not held`
+}
+
+// --- RWMutex / Read Lock Violations ---
+
+func (pr *ProtectedResource) GetReadGuardedValueCorrect() int {
+	pr.rwMu.RLock()
+	defer pr.rwMu.RUnlock()
+	return pr.readGuardedValue
+}
+
+func (pr *ProtectedResource) GetReadGuardedValueIncorrect() int {
+	return pr.readGuardedValue // want `rwMu \(.*\) must be locked when accessing readGuardedValue`
+}
+
+// +checklocksread:pr.rwMu
+func (pr *ProtectedResource) readDataRLocked() int {
+	return pr.readGuardedValue
+}
+
+func (pr *ProtectedResource) CallReadDataRLockedCorrect() int {
+	pr.rwMu.RLock()
+	v := pr.readDataRLocked()
+	pr.rwMu.RUnlock()
+	return v
+}
+
+func (pr *ProtectedResource) CallReadDataRLockedIncorrect() int {
+	return pr.readDataRLocked() // want `must hold pr.rwMu non-exclusively .* to call readDataRLocked, but not held`
+}
+
+// --- Atomic Violations ---
+
+func (pr *ProtectedResource) IncrementAtomicCorrect() {
+	atomic.AddInt32(&pr.atomicValue, 1)
+}
+
+func (pr *ProtectedResource) IncorrectDirectReadAtomic() int32 {
+	return pr.atomicValue // want `illegal use of atomic-only field by \*ssa.UnOp instruction`
+}
+
+func (pr *ProtectedResource) IncorrectDirectWriteAtomic() {
+	pr.atomicValue = 99 // want `illegal use of atomic-only field by \*ssa.Store instruction` `non-atomic write of field atomicValue`
+}
+
+// --- Mixed Mode Violations ---
+
+func (pr *ProtectedResource) ReadMixedCorrectAtomic() int32 {
+	return atomic.LoadInt32(&pr.mixedValue)
+}
+
+func (pr *ProtectedResource) WriteMixedCorrect(v int32) {
+	pr.mu.Lock()
+	atomic.StoreInt32(&pr.mixedValue, v)
+	pr.mu.Unlock()
+}
+
+func (pr *ProtectedResource) WriteMixedIncorrectAtomicOnly(v int32) {
+	atomic.StoreInt32(&pr.mixedValue, v) // want `unexpected call to atomic write function, is a lock missing\?`
+}
+
+func (pr *ProtectedResource) WriteMixedIncorrectLockOnly(v int32) {
+	pr.mu.Lock()
+	pr.mixedValue = v // want `illegal use of atomic-only field by \*ssa.Store instruction` `non-atomic write of field mixedValue, writes must still be atomic with locks held \(locks: .*exclusively\)`
+	pr.mu.Unlock()
+}
+
+func (pr *ProtectedResource) WriteMixedIncorrectNeither(v int32) {
+	pr.mixedValue = v // want `illegal use of atomic-only field by \*ssa.Store instruction` `non-atomic write of field mixedValue.*\(locks: no locks held\)`
+}
+
+// --- Acquire/Release Violations ---
+
+// +checklocksacquire:pr.acquireReleaseMu
+func (pr *ProtectedResource) AcquireAndSet(v int) {
+	pr.acquireReleaseMu.Lock()
+	pr.acquireReleaseValue = v
+}
+
+// +checklocksrelease:pr.acquireReleaseMu
+func (pr *ProtectedResource) GetAndRelease() int {
+	v := pr.acquireReleaseValue
+	pr.acquireReleaseMu.Unlock()
+	return v
+}
+
+func (pr *ProtectedResource) CallAcquireReleaseCorrect() int {
+	pr.AcquireAndSet(1)
+	return pr.GetAndRelease()
+}
+
+func (pr *ProtectedResource) CallAcquireReleaseIncorrectAcquire() {
+	pr.acquireReleaseMu.Lock()
+	pr.AcquireAndSet(2) // want `attempt to acquire pr.acquireReleaseMu .*, but already held`
+	pr.acquireReleaseMu.Unlock()
+}
+
+func (pr *ProtectedResource) CallAcquireReleaseIncorrectRelease() int {
+	return pr.GetAndRelease() // want `must hold pr.acquireReleaseMu exclusively .* to call GetAndRelease, but not held` `attempt to release pr.acquireReleaseMu .*, but not held`
+}
+
+// --- Ignore/Force ---
+
+// +checklocksignore
+func (pr *ProtectedResource) FunctionToIgnore() {
+	pr.value = -1 // Not reported.
+}
+
+func (pr *ProtectedResource) ForceExample() {
+	pr.value = -2 // want `mu \(.*\) must be locked when accessing value`
+
+	_ = pr.value // +checklocksforce: pr.mu
+
+	// The force only tells the analyzer mu is held; nothing releases it.
+	pr.description = "forced"
+}
+
+// want-nopos `return with unexpected locks held`
@@ -0,0 +1,205 @@
+// Package vettest checks the diagnostics of a go vet tool against
+// expectations written in the analyzed source, in the style of
+// golang.org/x/tools/go/analysis/analysistest.
+//
+// analysistest runs an *analysis.Analyzer in process, but checklocks is
+// consumed here the way the Makefile consumes it: bundled in the lockvet vet
+// tool binary. vettest runs `go vet -vettool=<tool> -json` over a fixture
+// module and compares the result with `// want` comments:
+//
+//	pr.value = val // want `must be locked when accessing value`
+//
+// Each quoted string (either quote style, several allowed) is a regular
+// expression that must match one diagnostic reported on that line. Every
+// diagnostic must be matched and every expectation used, so a test fails
+// both when a violation stops being detected and when a new one appears.
+//
+// Diagnostics reported without a position, such as checklocks' "return with
+// unexpected locks held", are matched by `// want-nopos` comments anywhere
+// in the fixture.
+package vettest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kakkoyun/checklocks-demo/internal/vetrun"
+)
+
+// Diagnostic is one finding from the vet tool's -json output.
+type Diagnostic = vetrun.Diagnostic
+
+// expectation is one regexp from a want comment.
+type expectation struct {
+	posn string // "file:line", or "" for want-nopos.
+	re   *regexp.Regexp
+	used bool
+}
+
+// Run vets the packages matching patterns inside dir, the root of a fixture
+// module, with the vet tool at path tool, and reports mismatches against the
+// fixture's want comments as test errors. Patterns may be preceded by vet
+// tool flags, such as -checklocks to run only that analyzer.
+func Run(t *testing.T, tool, dir string, patterns ...string) {
+	t.Helper()
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := expectations(dir, patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags, err := Vet(tool, dir, patterns...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range diags {
+		key := ""
+		if d.Posn != "-" {
+			key = lineOf(d.Posn)
+		}
+		if !match(want, key, d.Message) {
+			t.Errorf("%s: unexpected diagnostic: %s", rel(dir, d.Posn), d.Message)
+		}
+	}
+	for _, e := range want {
+		if !e.used {
+			posn := "(no position)"
+			if e.posn != "" {
+				posn = rel(dir, e.posn)
+			}
+			t.Errorf("%s: no diagnostic was reported matching %#q", posn, e.re)
+		}
+	}
+}
+
+// match marks the first unused expectation at key that matches msg.
+func match(want []*expectation, key, msg string) bool {
+	for _, e := range want {
+		if !e.used && e.posn == key && e.re.MatchString(msg) {
+			e.used = true
+			return true
+		}
+	}
+	return false
+}
+
+// Vet runs tool over patterns, optionally preceded by flags, in dir and
+// returns its diagnostics.
+func Vet(tool, dir string, patterns ...string) ([]Diagnostic, error) {
+	return vetrun.Vet(tool, dir, patterns...)
+}
+
+// expectations collects the want comments of the Go files in the packages
+// matched by patterns, which are directory patterns relative to dir such as
+// "./resource" or "./...".
+func expectations(dir string, patterns []string) ([]*expectation, error) {
+	var want []*expectation
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") {
+			return err
+		}
+		if !matchesAny(dir, filepath.Dir(path), patterns) {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		for _, cg := range f.Comments {
+			for _, c := range cg.List {
+				es, err := parseWant(fset, c)
+				if err != nil {
+					return err
+				}
+				want = append(want, es...)
+			}
+		}
+		return nil
+	})
+	return want, err
+}
+
+// matchesAny reports whether pkgDir is selected by one of patterns.
+func matchesAny(dir, pkgDir string, patterns []string) bool {
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "-") {
+			continue // A vet tool flag.
+		}
+		root, recursive := strings.CutSuffix(p, "/...")
+		root = filepath.Join(dir, root)
+		if pkgDir == root {
+			return true
+		}
+		if recursive && strings.HasPrefix(pkgDir, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWant parses a comment of the form `// want "re" ...` or
+// `// want-nopos "re" ...`. The want may also follow other text in the
+// comment, as in `// +checklocksignore // want "re"`.
+func parseWant(fset *token.FileSet, c *ast.Comment) ([]*expectation, error) {
+	text := c.Text
+	i := strings.Index(text, "// want")
+	if i < 0 {
+		return nil, nil
+	}
+	text = text[i+len("// want"):]
+	posn := fset.Position(c.Pos())
+	key := fmt.Sprintf("%s:%d", posn.Filename, posn.Line)
+	if rest, ok := strings.CutPrefix(text, "-nopos"); ok {
+		text, key = rest, ""
+	} else if text != "" && text[0] != ' ' {
+		return nil, nil
+	}
+
+	var es []*expectation
+	for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+		lit, err := strconv.QuotedPrefix(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: malformed want comment: %v", posn, err)
+		}
+		text = text[len(lit):]
+		s, _ := strconv.Unquote(lit)
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", posn, err)
+		}
+		es = append(es, &expectation{posn: key, re: re})
+	}
+	if len(es) == 0 {
+		return nil, fmt.Errorf("%s: want comment without expectations", posn)
+	}
+	return es, nil
+}
+
+// lineOf trims the column from a "file:line:col" position.
+func lineOf(posn string) string {
+	if i := strings.LastIndexByte(posn, ':'); i >= 0 {
+		if _, err := strconv.Atoi(posn[i+1:]); err == nil && strings.Count(posn[:i], ":") >= 1 {
+			return posn[:i]
+		}
+	}
+	return posn
+}
+
+func rel(dir, posn string) string {
+	if r, err := filepath.Rel(dir, posn); err == nil && !strings.HasPrefix(r, "..") {
+		return r
+	}
+	return posn
+}
@@ -0,0 +1,209 @@
+package vettest
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kakkoyun/checklocks-demo/internal/annotation"
+)
+
+// buildTool builds the named command of this module, such as lockvet, into
+// a temporary directory and returns its path.
+func buildTool(t *testing.T, cmd string) string {
+	t.Helper()
+	tool := filepath.Join(t.TempDir(), cmd)
+	if out, err := exec.Command("go", "build", "-o", tool, "github.com/kakkoyun/checklocks-demo/cmd/"+cmd).CombinedOutput(); err != nil {
+		t.Fatalf("building %s: %v\n%s", cmd, err, out)
+	}
+	return tool
+}
+
+// TestChecklocks pins every violation the demo packages rely on against the
+// checklocks analyzer bundled in lockvet.
+func TestChecklocks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a vet tool")
+	}
+	tool := buildTool(t, "lockvet")
+	for _, pkg := range []string{"resource", "genericresource"} {
+		t.Run(pkg, func(t *testing.T) {
+			Run(t, tool, "testdata/src", "-checklocks", "./"+pkg)
+		})
+	}
+}
+
+// TestHarness runs the harness against lockvet's lockorder analyzer.
+func TestHarness(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a vet tool")
+	}
+	tool := buildTool(t, "lockvet")
+	Run(t, tool, "testdata/src", "-lockorder", "./lockorder")
+
+	diags, err := Vet(tool, "testdata/src", "-lockorder", "./lockorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 {
+		t.Errorf("Vet returned %d diagnostics, want 1: %v", len(diags), diags)
+	}
+}
+
+// genericNames maps the names in generic.go to those of its non-generic
+// twin, non_generic.go.
+var (
+	genericNames   = regexp.MustCompile(`\b(GenericResource|gr)\b`)
+	nonGenericName = map[string]string{"GenericResource": "NonGenericResource", "gr": "ngr"}
+)
+
+// TestGenericDifferential runs each lock analyzer, checklocks included, over
+// pkg/genericresource, and over the differential fixture, whose twin files
+// carry the same deliberate violations, and checks that GenericResource[T]
+// in generic.go gets the same raw diagnostics as NonGenericResource in
+// non_generic.go, once type and receiver names are made to match: generic
+// receivers must resolve gr.mu and friends exactly as non-generic ones do.
+// Only declarations present in both files are compared, and nothing is
+// filtered out.
+func TestGenericDifferential(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds vet tools")
+	}
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := filepath.Abs("testdata/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range []string{"lockvet", "lockinfer"} {
+		t.Run(cmd, func(t *testing.T) {
+			tool := buildTool(t, cmd)
+			differential(t, tool, root, "pkg/genericresource", false)
+			differential(t, tool, fixtures, "differential", true)
+		})
+	}
+}
+
+// differential compares the diagnostics tool reports for generic.go and
+// non_generic.go in dir/pkg. If nonEmpty, non_generic.go must have some.
+func differential(t *testing.T, tool, dir, pkg string, nonEmpty bool) {
+	t.Helper()
+	fset := token.NewFileSet()
+	files := map[string]*ast.File{}
+	decls := map[string]map[string]bool{}
+	for _, name := range []string{"generic.go", "non_generic.go"} {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, pkg, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = f
+		decls[name] = map[string]bool{}
+		for _, d := range f.Decls {
+			for _, n := range declNames(d) {
+				if name == "generic.go" {
+					n = renameGeneric(n)
+				}
+				decls[name][n] = true
+			}
+		}
+	}
+
+	diags, err := Vet(tool, dir, "./"+pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := map[string][]string{}
+	for _, d := range diags {
+		file, line := splitLine(d.Posn)
+		file = filepath.Base(file)
+		f := files[file]
+		if f == nil {
+			continue
+		}
+		fn, msg := declAt(fset, f, line), d.Message
+		if file == "generic.go" {
+			fn, msg = renameGeneric(fn), renameGeneric(msg)
+		}
+		if !decls["generic.go"][fn] || !decls["non_generic.go"][fn] {
+			continue
+		}
+		findings[file] = append(findings[file], fn+": "+msg)
+	}
+	generic, nonGeneric := findings["generic.go"], findings["non_generic.go"]
+	slices.Sort(generic)
+	slices.Sort(nonGeneric)
+	if nonEmpty && len(nonGeneric) == 0 {
+		t.Errorf("%s: no findings on non_generic.go; the comparison is vacuous", pkg)
+	}
+	if !slices.Equal(generic, nonGeneric) {
+		t.Errorf("%s: findings differ between generic.go and non_generic.go:\ngeneric:\n\t%s\nnon-generic:\n\t%s",
+			pkg, strings.Join(generic, "\n\t"), strings.Join(nonGeneric, "\n\t"))
+	}
+}
+
+func renameGeneric(s string) string {
+	return genericNames.ReplaceAllStringFunc(s, func(s string) string { return nonGenericName[s] })
+}
+
+// declNames names the functions and types d declares, methods as
+// "Type.Method".
+func declNames(d ast.Decl) []string {
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		return []string{annotation.FuncName(d)}
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range d.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok {
+				names = append(names, ts.Name.Name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// declAt names the top-level declaration of f spanning line, or "".
+func declAt(fset *token.FileSet, f *ast.File, line int) string {
+	for _, d := range f.Decls {
+		if fset.Position(d.Pos()).Line > line || fset.Position(d.End()).Line < line {
+			continue
+		}
+		if names := declNames(d); len(names) == 1 {
+			return names[0]
+		}
+	}
+	return ""
+}
+
+// splitLine splits a "file:line:col" position into file and line.
+func splitLine(posn string) (string, int) {
+	posn = lineOf(posn)
+	i := strings.LastIndexByte(posn, ':')
+	if i < 0 {
+		return posn, 0
+	}
+	line, _ := strconv.Atoi(posn[i+1:])
+	return posn[:i], line
+}
+
+func TestLineOf(t *testing.T) {
+	for in, want := range map[string]string{
+		"/x/a.go:3:2": "/x/a.go:3",
+		"a.go:10:1":   "a.go:10",
+		"-":           "-",
+	} {
+		if got := lineOf(in); got != want {
+			t.Errorf("lineOf(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package watch implements the change-notification fan-out shared by the
+// resource packages.
+package watch
+
+import (
+	"context"
+	"sync"
+)
+
+// Policy decides what Publish does when a watcher's buffer is full.
+type Policy int
+
+const (
+	// DropOldest discards the oldest buffered event to make room for the new
+	// one. Writers never block; a slow consumer sees a gap in versions.
+	DropOldest Policy = iota
+	// Block makes Publish wait until the watcher has room or its context is
+	// done. Every event is delivered, but a slow consumer slows down writers,
+	// which publish while holding the resource's lock.
+	Block
+)
+
+// DefaultBuffer is the per-watcher buffer size used when none is given.
+const DefaultBuffer = 16
+
+// Option configures a single watcher.
+type Option func(*config)
+
+type config struct {
+	buffer int
+	policy Policy
+}
+
+// WithBuffer sets the watcher's channel capacity. Values below 1 are treated as 1.
+func WithBuffer(n int) Option {
+	return func(c *config) { c.buffer = max(n, 1) }
+}
+
+// WithPolicy sets the watcher's slow-consumer policy.
+func WithPolicy(p Policy) Option {
+	return func(c *config) { c.policy = p }
+}
+
+type watcher[E any] struct {
+	ctx    context.Context
+	ch     chan E
+	policy Policy
+}
+
+// Hub fans events out to watchers and stamps each with a version.
+// The zero value is ready to use.
+type Hub[E any] struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	watchers map[*watcher[E]]struct{}
+	// +checklocks:mu
+	version uint64
+}
+
+// Subscribe registers a watcher that receives events until ctx is done, at
+// which point its channel is closed.
+func (h *Hub[E]) Subscribe(ctx context.Context, opts ...Option) <-chan E {
+	cfg := config{buffer: DefaultBuffer, policy: DropOldest}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	w := &watcher[E]{ctx: ctx, ch: make(chan E, cfg.buffer), policy: cfg.policy}
+
+	h.mu.Lock()
+	if h.watchers == nil {
+		h.watchers = make(map[*watcher[E]]struct{})
+	}
+	h.watchers[w] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.watchers, w)
+		close(w.ch) // Safe: Publish only sends while holding h.mu.
+		h.mu.Unlock()
+	}()
+	return w.ch
+}
+
+// Publish assigns the next version and delivers the event built by mk to
+// every watcher according to its policy. mk is only called if there is at
+// least one watcher, so callers pay nothing when nobody is watching.
+func (h *Hub[E]) Publish(mk func(version uint64) E) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.version++
+	if len(h.watchers) == 0 {
+		return
+	}
+	e := mk(h.version)
+	for w := range h.watchers {
+		w.send(e)
+	}
+}
+
+// send delivers e according to w's policy. It must be called with the hub's
+// lock held, which makes Publish the only sender.
+func (w *watcher[E]) send(e E) {
+	if w.policy == Block {
+		select {
+		case w.ch <- e:
+		case <-w.ctx.Done():
+		}
+		return
+	}
+	for {
+		select {
+		case w.ch <- e:
+			return
+		default:
+		}
+		// Full: drop the oldest event and retry.
+		select {
+		case <-w.ch:
+		default:
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package watch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPublishWithoutWatchers(t *testing.T) {
+	var h Hub[uint64]
+	called := false
+	h.Publish(func(v uint64) uint64 { called = true; return v })
+	if called {
+		t.Errorf("Publish built an event with no watchers")
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	var h Hub[uint64]
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := h.Subscribe(ctx, WithBuffer(2))
+
+	for range 5 {
+		h.Publish(func(v uint64) uint64 { return v })
+	}
+	if got := <-ch; got != 4 {
+		t.Errorf("expected oldest retained version 4, got %d", got)
+	}
+	if got := <-ch; got != 5 {
+		t.Errorf("expected newest version 5, got %d", got)
+	}
+}
+
+func TestBlockDeliversEverything(t *testing.T) {
+	var h Hub[uint64]
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := h.Subscribe(ctx, WithBuffer(1), WithPolicy(Block))
+
+	const n = 10
+	go func() {
+		for range n {
+			h.Publish(func(v uint64) uint64 { return v })
+		}
+	}()
+	for want := uint64(1); want <= n; want++ {
+		if got := <-ch; got != want {
+			t.Fatalf("expected version %d, got %d", want, got)
+		}
+	}
+}
+
+func TestBlockUnblocksOnCancel(t *testing.T) {
+	var h Hub[uint64]
+	ctx, cancel := context.WithCancel(context.Background())
+	_ = h.Subscribe(ctx, WithBuffer(1), WithPolicy(Block))
+
+	h.Publish(func(v uint64) uint64 { return v }) // Fills the buffer.
+	done := make(chan struct{})
+	go func() {
+		h.Publish(func(v uint64) uint64 { return v }) // Blocks until cancel.
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish still blocked after the watcher's context was canceled")
+	}
+}
+
+func TestChannelClosedOnCancel(t *testing.T) {
+	var h Hub[uint64]
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := h.Subscribe(ctx)
+	cancel()
+	for range ch {
+	}
+	// Publishing after the watcher is gone must not panic.
+	h.Publish(func(v uint64) uint64 { return v })
+}
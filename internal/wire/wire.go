@@ -0,0 +1,112 @@
+// Package wire holds the small varint/length-prefixed helpers behind the
+// resource packages' MarshalBinary formats.
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrShort is returned when the input ends in the middle of a field.
+var ErrShort = errors.New("wire: short buffer")
+
+// Encoder appends fields to a byte slice.
+type Encoder struct {
+	Buf []byte
+}
+
+// Uvarint appends v as an unsigned varint.
+func (e *Encoder) Uvarint(v uint64) {
+	e.Buf = binary.AppendUvarint(e.Buf, v)
+}
+
+// Varint appends v as a signed varint.
+func (e *Encoder) Varint(v int64) {
+	e.Buf = binary.AppendVarint(e.Buf, v)
+}
+
+// Bytes appends b with a length prefix.
+func (e *Encoder) Bytes(b []byte) {
+	e.Uvarint(uint64(len(b)))
+	e.Buf = append(e.Buf, b...)
+}
+
+// String appends s with a length prefix.
+func (e *Encoder) String(s string) {
+	e.Uvarint(uint64(len(s)))
+	e.Buf = append(e.Buf, s...)
+}
+
+// Decoder consumes fields from a byte slice. The first error sticks: later
+// calls return zero values and Err reports it.
+type Decoder struct {
+	buf []byte
+	err error
+}
+
+// NewDecoder returns a Decoder reading from b.
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{buf: b}
+}
+
+// Err returns the first decoding error, if any.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// Finish returns the first decoding error, or an error if input remains.
+func (d *Decoder) Finish() error {
+	if d.err == nil && len(d.buf) != 0 {
+		d.err = fmt.Errorf("wire: %d trailing bytes", len(d.buf))
+	}
+	return d.err
+}
+
+// Uvarint consumes an unsigned varint.
+func (d *Decoder) Uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = ErrShort
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+// Varint consumes a signed varint.
+func (d *Decoder) Varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = ErrShort
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+// Bytes consumes a length-prefixed byte slice. The result aliases the input.
+func (d *Decoder) Bytes() []byte {
+	n := d.Uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.buf)) < n {
+		d.err = ErrShort
+		return nil
+	}
+	b := d.buf[:n:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+// String consumes a length-prefixed string.
+func (d *Decoder) String() string {
+	return string(d.Bytes())
+}
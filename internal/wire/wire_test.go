@@ -0,0 +1,52 @@
+package wire
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var e Encoder
+	e.Uvarint(1 << 40)
+	e.Varint(-7)
+	e.String("hello")
+	e.Bytes([]byte{1, 2, 3})
+
+	d := NewDecoder(e.Buf)
+	if got := d.Uvarint(); got != 1<<40 {
+		t.Errorf("Uvarint: expected %d, got %d", uint64(1<<40), got)
+	}
+	if got := d.Varint(); got != -7 {
+		t.Errorf("Varint: expected -7, got %d", got)
+	}
+	if got := d.String(); got != "hello" {
+		t.Errorf("String: expected hello, got %q", got)
+	}
+	if got := d.Bytes(); string(got) != "\x01\x02\x03" {
+		t.Errorf("Bytes: expected 010203, got %x", got)
+	}
+	if err := d.Finish(); err != nil {
+		t.Errorf("Finish: %v", err)
+	}
+}
+
+func TestShortInput(t *testing.T) {
+	var e Encoder
+	e.String("truncated")
+	d := NewDecoder(e.Buf[:4])
+	_ = d.String()
+	if !errors.Is(d.Err(), ErrShort) {
+		t.Errorf("expected ErrShort, got %v", d.Err())
+	}
+	if got := d.Varint(); got != 0 {
+		t.Errorf("expected zero after error, got %d", got)
+	}
+}
+
+func TestTrailingBytes(t *testing.T) {
+	d := NewDecoder([]byte{0, 0})
+	_ = d.Uvarint()
+	if err := d.Finish(); err == nil {
+		t.Error("expected an error for trailing bytes")
+	}
+}
@@ -0,0 +1,364 @@
+// Package forceaudit defines an analyzer that inventories +checklocksforce
+// escape hatches.
+//
+// A comment such as
+//
+//	_ = pr.value // +checklocksforce: pr.mu
+//
+// makes checklocks believe pr.mu is held from that line to the end of the
+// function, without anything acquiring it. The analyzer reports two kinds
+// of force as problems:
+//
+//   - one that no access relies on, which suppresses nothing and usually
+//     names the wrong lock;
+//   - one whose lock is still considered held at a later return, or at the
+//     end of the function, which is what makes checklocks report "return
+//     with unexpected locks held".
+//
+// An access relies on a force if it comes after the forced line, before the
+// lock is released (by Unlock, RUnlock or a +checklocksrelease function),
+// and needs the lock: a field annotated +checklocks:mu, a call to a function
+// annotated +checklocks, +checklocksread or +checklocksrelease on it, or an
+// Unlock of it.
+//
+// With -inventory, it also reports every other force site with the
+// accesses that rely on it, so that the set of forces can be reviewed like
+// any other finding and recorded in a baseline. lockvet report sets it; a
+// plain vet run does not, since the sites are not problems in themselves:
+//
+//	+checklocksforce:pr.mu covers pr.value, pr.description
+//
+// The analysis is positional rather than path-sensitive: a release anywhere
+// between the force and a return counts, and a deferred release covers
+// every return.
+package forceaudit
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/kakkoyun/checklocks-demo/internal/annotation"
+)
+
+// Analyzer reports +checklocksforce sites that are unused or leak past a
+// return and, with -inventory, every other site.
+var Analyzer = &analysis.Analyzer{
+	Name:      "forceaudit",
+	Doc:       "report +checklocksforce sites that are unused or leak past a return; with -inventory, every site and the accesses relying on it",
+	URL:       "https://pkg.go.dev/github.com/kakkoyun/checklocks-demo/pkg/analysis/forceaudit",
+	Run:       run,
+	FactTypes: []analysis.Fact{new(guardFact), new(funcFact)},
+}
+
+// inventory is the -inventory flag.
+var inventory bool
+
+func init() {
+	Analyzer.Flags.BoolVar(&inventory, "inventory", false, "also report every force site with the accesses relying on it, for review")
+}
+
+const forcePrefix = "+checklocksforce:"
+
+// guardFact records the guard of a struct field.
+type guardFact struct {
+	annotation.Guard
+}
+
+func (*guardFact) AFact() {}
+
+// funcFact records the lock annotations of a function, as paths rooted at
+// its receiver or parameters.
+type funcFact struct {
+	Requires []string // +checklocks, +checklocksread.
+	Releases []string // +checklocksrelease, +checklocksreleaseread.
+}
+
+func (*funcFact) AFact() {}
+
+func (f *funcFact) String() string {
+	var parts []string
+	if len(f.Requires) > 0 {
+		parts = append(parts, "requires("+strings.Join(f.Requires, ",")+")")
+	}
+	if len(f.Releases) > 0 {
+		parts = append(parts, "releases("+strings.Join(f.Releases, ",")+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+type checker struct {
+	pass *analysis.Pass
+	// params holds the receiver and parameter names of this package's
+	// annotated functions, in order, for translating their paths.
+	params map[*types.Func][]string
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	c := &checker{pass: pass, params: make(map[*types.Func][]string)}
+	c.collectGuards()
+	for _, file := range pass.Files {
+		for _, d := range file.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok {
+				c.collectFunc(fd)
+			}
+		}
+	}
+	for _, file := range pass.Files {
+		for _, d := range file.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Body != nil {
+				for _, f := range c.forces(file, fd) {
+					c.audit(fd, f)
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// collectGuards exports a guardFact for every +checklocks field of the
+// package's struct types.
+func (c *checker) collectGuards() {
+	for v, g := range annotation.Guards(c.pass.Files, c.pass.TypesInfo) {
+		c.pass.ExportObjectFact(v, &guardFact{g})
+	}
+}
+
+// collectFunc exports a funcFact for a function with lock preconditions or
+// releases.
+func (c *checker) collectFunc(fd *ast.FuncDecl) {
+	fn, ok := c.pass.TypesInfo.Defs[fd.Name].(*types.Func)
+	if !ok {
+		return
+	}
+	var ff funcFact
+	for _, text := range annotation.List(fd.Doc) {
+		kind, path, ok := strings.Cut(text, ":")
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "+checklocks", "+checklocksread":
+			ff.Requires = append(ff.Requires, path)
+		case "+checklocksrelease", "+checklocksreleaseread":
+			ff.Releases = append(ff.Releases, path)
+		}
+	}
+	if len(ff.Requires)+len(ff.Releases) > 0 {
+		c.pass.ExportObjectFact(fn, &ff)
+	}
+}
+
+// force is one +checklocksforce comment.
+type force struct {
+	comment *ast.Comment
+	path    string    // e.g. "pr.mu"
+	from    token.Pos // Start of the forced line.
+}
+
+// forces returns the +checklocksforce comments inside fd's body.
+func (c *checker) forces(file *ast.File, fd *ast.FuncDecl) []force {
+	tf := c.pass.Fset.File(fd.Pos())
+	var out []force
+	for _, cg := range file.Comments {
+		if cg.Pos() < fd.Body.Lbrace || cg.End() > fd.Body.Rbrace {
+			continue
+		}
+		for _, cm := range cg.List {
+			text, ok := annotation.Parse(cm.Text)
+			if !ok {
+				continue
+			}
+			if path, ok := strings.CutPrefix(text, forcePrefix); ok {
+				out = append(out, force{comment: cm, path: path, from: tf.LineStart(tf.Line(cm.Pos()))})
+			}
+		}
+	}
+	return out
+}
+
+// use is an access that needs a lock, or releases it.
+type use struct {
+	pos     token.Pos
+	desc    string
+	release bool
+}
+
+func (c *checker) audit(fd *ast.FuncDecl, f force) {
+	uses, returns, deferred := c.scan(fd, f.path)
+
+	var covered []use
+	end := fd.Body.Rbrace
+	for _, u := range uses {
+		if u.pos < f.from {
+			continue
+		}
+		covered = append(covered, u)
+		if u.release {
+			end = u.pos
+			break
+		}
+	}
+
+	name := annotation.FuncName(fd)
+	if len(covered) == 0 {
+		c.pass.Reportf(f.comment.Pos(), "%s%s is not followed by any access that requires %s in %s", forcePrefix, f.path, f.path, name)
+	} else if inventory {
+		var descs []string
+		var related []analysis.RelatedInformation
+		for _, u := range covered {
+			if !slices.Contains(descs, u.desc) {
+				descs = append(descs, u.desc)
+			}
+			related = append(related, analysis.RelatedInformation{Pos: u.pos, Message: "relies on " + forcePrefix + f.path})
+		}
+		c.pass.Report(analysis.Diagnostic{
+			Pos:     f.comment.Pos(),
+			Message: fmt.Sprintf("%s%s covers %s", forcePrefix, f.path, strings.Join(descs, ", ")),
+			Related: related,
+		})
+	}
+
+	if deferred {
+		return
+	}
+	for _, r := range returns {
+		if r > f.from && r < end {
+			c.pass.Reportf(f.comment.Pos(), "%s%s leaks past the return at line %d: %s is still considered held there",
+				forcePrefix, f.path, c.pass.Fset.Position(r).Line, f.path)
+			return
+		}
+	}
+	if end == fd.Body.Rbrace && !endsInReturn(fd.Body) {
+		c.pass.Reportf(f.comment.Pos(), "%s%s leaks past the end of %s: %s is still considered held there",
+			forcePrefix, f.path, name, f.path)
+	}
+}
+
+// scan returns, in source order, the uses of the lock at path in fd outside
+// function literals, the positions of its return statements, and whether a
+// deferred call releases the lock.
+func (c *checker) scan(fd *ast.FuncDecl, path string) (uses []use, returns []token.Pos, deferred bool) {
+	info := c.pass.TypesInfo
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			returns = append(returns, n.Pos())
+		case *ast.DeferStmt:
+			if u, ok := c.callUse(n.Call, path); ok && u.release {
+				deferred = true
+			}
+			return false
+		case *ast.CallExpr:
+			if u, ok := c.callUse(n, path); ok {
+				uses = append(uses, u)
+			}
+		case *ast.SelectorExpr:
+			s := info.Selections[n]
+			if s == nil || s.Kind() != types.FieldVal || len(s.Index()) != 1 {
+				return true
+			}
+			var gf guardFact
+			if !c.pass.ImportObjectFact(s.Obj().(*types.Var).Origin(), &gf) {
+				return true
+			}
+			base := types.ExprString(ast.Unparen(n.X))
+			for _, mu := range gf.Mutexes {
+				if base+"."+mu == path {
+					uses = append(uses, use{pos: n.Pos(), desc: base + "." + n.Sel.Name})
+				}
+			}
+		}
+		return true
+	})
+	slices.SortStableFunc(uses, func(a, b use) int { return int(a.pos - b.pos) })
+	return uses, returns, deferred
+}
+
+// callUse reports whether call needs the lock at path held, and whether it
+// releases it.
+func (c *checker) callUse(call *ast.CallExpr, path string) (use, bool) {
+	fn := typeutil.StaticCallee(c.pass.TypesInfo, call)
+	if fn == nil {
+		return use{}, false
+	}
+	fn = fn.Origin()
+	sel, isSel := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	recv := fn.Signature().Recv()
+	if recv != nil && isSel && annotation.IsMutex(annotation.Deref(recv.Type())) {
+		switch fn.Name() {
+		case "Unlock", "RUnlock":
+			if types.ExprString(ast.Unparen(sel.X)) == path {
+				return use{pos: call.Pos(), desc: path + "." + fn.Name(), release: true}, true
+			}
+		}
+		return use{}, false
+	}
+	var ff funcFact
+	if !c.pass.ImportObjectFact(fn, &ff) {
+		return use{}, false
+	}
+	callee := fn.Name()
+	if isSel {
+		callee = types.ExprString(ast.Unparen(sel.X)) + "." + fn.Name()
+	}
+	for _, p := range ff.Releases {
+		if translate(fn, call, p) == path {
+			return use{pos: call.Pos(), desc: "call to " + callee, release: true}, true
+		}
+	}
+	for _, p := range ff.Requires {
+		if translate(fn, call, p) == path {
+			return use{pos: call.Pos(), desc: "call to " + callee}, true
+		}
+	}
+	return use{}, false
+}
+
+// translate rewrites a path of fn's annotation, rooted at its receiver or a
+// parameter, into an expression path at call.
+func translate(fn *types.Func, call *ast.CallExpr, path string) string {
+	root, rest, ok := strings.Cut(path, ".")
+	if !ok {
+		return ""
+	}
+	sig := fn.Signature()
+	if r := sig.Recv(); r != nil && r.Name() == root {
+		if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok {
+			return types.ExprString(ast.Unparen(sel.X)) + "." + rest
+		}
+		return ""
+	}
+	for i := range sig.Params().Len() {
+		if sig.Params().At(i).Name() == root && i < len(call.Args) {
+			return types.ExprString(ast.Unparen(call.Args[i])) + "." + rest
+		}
+	}
+	return ""
+}
+
+func endsInReturn(body *ast.BlockStmt) bool {
+	if len(body.List) == 0 {
+		return false
+	}
+	switch s := body.List[len(body.List)-1].(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.ExprStmt:
+		call, ok := s.X.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		id, ok := ast.Unparen(call.Fun).(*ast.Ident)
+		return ok && id.Name == "panic"
+	}
+	return false
+}
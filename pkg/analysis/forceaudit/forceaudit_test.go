@@ -0,0 +1,21 @@
+package forceaudit_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/kakkoyun/checklocks-demo/pkg/analysis/forceaudit"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), forceaudit.Analyzer, "lib", "force")
+}
+
+func TestInventory(t *testing.T) {
+	if err := forceaudit.Analyzer.Flags.Set("inventory", "true"); err != nil {
+		t.Fatal(err)
+	}
+	defer forceaudit.Analyzer.Flags.Set("inventory", "false")
+	analysistest.Run(t, analysistest.TestData(), forceaudit.Analyzer, "lib", "inventory")
+}
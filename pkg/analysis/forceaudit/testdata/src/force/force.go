@@ -0,0 +1,105 @@
+package force
+
+import (
+	"sync"
+
+	"lib"
+)
+
+type R struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	value int // want value:`guarded\(mu\)`
+	// +checklocks:mu
+	description string // want description:`guarded\(mu\)`
+
+	rw sync.RWMutex
+	// +checklocks:rw
+	read int // want read:`guarded\(rw\)`
+}
+
+// +checklocks:r.mu
+func (r *R) setLocked(v int) { // want setLocked:`requires\(r\.mu\)`
+	r.value = v
+}
+
+// +checklocksrelease:r.mu
+func (r *R) unlockMu() { // want unlockMu:`releases\(r\.mu\)`
+	r.mu.Unlock()
+}
+
+func tryLock(r *R) bool { return r.mu.TryLock() }
+
+func (r *R) covered() (int, string) {
+	if !tryLock(r) {
+		return 0, ""
+	}
+	v := r.value // +checklocksforce:r.mu
+	d := r.description
+	r.mu.Unlock()
+	return v, d
+}
+
+func (r *R) call() {
+	if !tryLock(r) {
+		return
+	}
+	r.setLocked(1) // +checklocksforce:r.mu
+	r.unlockMu()
+}
+
+func (r *R) deferred() {
+	if !tryLock(r) {
+		return
+	}
+	defer r.mu.Unlock()
+	r.value = 1 // +checklocksforce:r.mu
+	if r.value > 0 {
+		return
+	}
+	r.description = "x"
+}
+
+func (r *R) unused() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.read = 1 // +checklocksforce:r.mu // want `\+checklocksforce:r.mu is not followed by any access that requires r.mu in R.unused`
+}
+
+func (r *R) leaksEnd() {
+	_ = r.value // +checklocksforce: r.mu // want `\+checklocksforce:r.mu leaks past the end of R.leaksEnd: r.mu is still considered held there`
+	r.description = "forced"
+}
+
+func (r *R) leaksReturn(ok bool) int {
+	v := r.value // +checklocksforce:r.mu // want `\+checklocksforce:r.mu leaks past the return at line 77`
+	if !ok {
+		return v
+	}
+	r.mu.Unlock()
+	return 0
+}
+
+func (r *R) releasedBeforeReturn() int {
+	v := r.value // +checklocksforce:r.mu
+	r.mu.Unlock()
+	return v
+}
+
+func imported(c *lib.Counter) {
+	lib.IncLocked(c) // +checklocksforce:c.Mu
+	_ = c.N
+	c.Mu.Unlock()
+}
+
+type G[T any] struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	v T // want v:`guarded\(mu\)`
+}
+
+func (g *G[T]) get() T {
+	v := g.v // +checklocksforce:g.mu
+	g.mu.Unlock()
+	return v
+}
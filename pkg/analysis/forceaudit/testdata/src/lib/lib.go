@@ -0,0 +1,14 @@
+package lib
+
+import "sync"
+
+type Counter struct {
+	Mu sync.Mutex
+	// +checklocks:Mu
+	N int // want N:`guarded\(Mu\)`
+}
+
+// +checklocks:c.Mu
+func IncLocked(c *Counter) { // want IncLocked:`requires\(c\.Mu\)`
+	c.N++
+}
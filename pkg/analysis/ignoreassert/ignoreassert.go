@@ -0,0 +1,471 @@
+// Package ignoreassert defines an analyzer that keeps the +checklocksignore
+// escape hatch auditable.
+//
+// checklocks does not look inside a function annotated +checklocksignore,
+// so nothing checks that the locks its guarded field accesses rely on are
+// actually held. The convention, as in helperCalledUnderLock, is to state
+// the assumption as a runtime assertion at function entry:
+//
+//	// +checklocksignore
+//	func (pr *ProtectedResource) helperCalledUnderLock() {
+//		mutexasserts.AssertMutexLocked(&pr.mu)
+//		pr.value = -10
+//	}
+//
+// For every access in an ignored function to a field annotated
+// +checklocks:mu, through the receiver or a parameter, the analyzer requires
+// an assertion among the function's leading statements that the guarding
+// lock is held: mutexasserts.AssertMutexLocked for a sync.Mutex,
+// AssertRWMutexLocked for a written sync.RWMutex-guarded field and
+// AssertRWMutexRLocked (or either) for one that is only read. The
+// equivalent lockassert.Held, WHeld and RHeld checks also count. The
+// suggested fix inserts the missing mutexasserts calls.
+//
+// Accesses after the function acquires the lock itself need no assertion,
+// whether it locks directly, through a +checklocksacquire helper, or by
+// passing the mutex's address to a function annotated +checklocksacquire for
+// that parameter or to lockCtx, which acquires it conditionally and so
+// cannot be annotated (as AcquireAndSetCtx does). Accesses before the
+// acquisition still need one. Atomic reads of +checkatomic fields that are also lock-guarded need no
+// lock.
+package ignoreassert
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/kakkoyun/checklocks-demo/internal/annotation"
+)
+
+// Analyzer reports +checklocksignore functions that do not assert the locks
+// guarding the fields they access.
+var Analyzer = &analysis.Analyzer{
+	Name:      "ignoreassert",
+	Doc:       "check that +checklocksignore functions assert the locks guarding the fields they access",
+	URL:       "https://pkg.go.dev/github.com/kakkoyun/checklocks-demo/pkg/analysis/ignoreassert",
+	Run:       run,
+	FactTypes: []analysis.Fact{new(guardFact)},
+}
+
+const (
+	ignoreMarker     = "+checklocksignore"
+	mutexassertsPath = "github.com/trailofbits/go-mutexasserts"
+)
+
+// lockingHelpers are functions that acquire the mutex passed by address
+// only on success, which no annotation can express.
+var lockingHelpers = []string{"lockCtx"}
+
+// guardFact records the guard of a struct field.
+type guardFact struct {
+	annotation.Guard
+}
+
+func (*guardFact) AFact() {}
+
+type checker struct {
+	pass *analysis.Pass
+	// acquires maps this package's functions to the lock paths, rooted at
+	// their receiver or a parameter, they return holding.
+	acquires map[*types.Func][]string
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	c := &checker{pass: pass, acquires: make(map[*types.Func][]string)}
+	c.collectGuards()
+
+	var ignored []*ast.FuncDecl
+	files := make(map[*ast.FuncDecl]*ast.File)
+	for _, file := range pass.Files {
+		for _, d := range file.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok || fd.Body == nil {
+				continue
+			}
+			if fn, ok := pass.TypesInfo.Defs[fd.Name].(*types.Func); ok {
+				for _, text := range annotation.List(fd.Doc) {
+					if path, ok := cutAnyPrefix(text, "+checklocksacquire:", "+checklocksacquireread:"); ok {
+						c.acquires[fn] = append(c.acquires[fn], path)
+					}
+				}
+			}
+			if annotation.Has(fd.Doc, ignoreMarker) {
+				ignored = append(ignored, fd)
+				files[fd] = file
+			}
+		}
+	}
+	for _, fd := range ignored {
+		c.checkFunc(files[fd], fd)
+	}
+	return nil, nil
+}
+
+func cutAnyPrefix(s string, prefixes ...string) (string, bool) {
+	for _, p := range prefixes {
+		if rest, ok := strings.CutPrefix(s, p); ok {
+			return rest, true
+		}
+	}
+	return "", false
+}
+
+// collectGuards exports a guardFact for every +checklocks field of the
+// package's struct types.
+func (c *checker) collectGuards() {
+	for v, g := range annotation.Guards(c.pass.Files, c.pass.TypesInfo) {
+		c.pass.ExportObjectFact(v, &guardFact{g})
+	}
+}
+
+// need is a lock an ignored function relies on.
+type need struct {
+	path   string // e.g. "pr.mu"
+	rw     bool   // A sync.RWMutex.
+	write  bool   // Some guarded field is written.
+	fields []string
+}
+
+// assertion kinds.
+const (
+	assertNone = iota
+	assertRead
+	assertExclusive
+)
+
+func (c *checker) checkFunc(file *ast.File, fd *ast.FuncDecl) {
+	info := c.pass.TypesInfo
+	params := make(map[types.Object]bool)
+	for _, fl := range []*ast.FieldList{fd.Recv, fd.Type.Params} {
+		if fl == nil {
+			continue
+		}
+		for _, f := range fl.List {
+			for _, name := range f.Names {
+				params[info.Defs[name]] = true
+			}
+		}
+	}
+
+	// Leading assertions.
+	asserted := make(map[string]int)
+	for _, s := range fd.Body.List {
+		es, ok := s.(*ast.ExprStmt)
+		if !ok {
+			break
+		}
+		path, kind := c.assertion(es.X)
+		if kind == assertNone {
+			break
+		}
+		asserted[path] = max(asserted[path], kind)
+	}
+
+	writes := make(map[*ast.SelectorExpr]bool)
+	atomicReads := make(map[*ast.SelectorExpr]bool)
+	acquired := make(map[string]token.Pos) // Lock path to the end of its first acquisition.
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, l := range n.Lhs {
+				if sel, ok := ast.Unparen(l).(*ast.SelectorExpr); ok {
+					writes[sel] = true
+				}
+			}
+		case *ast.IncDecStmt:
+			if sel, ok := ast.Unparen(n.X).(*ast.SelectorExpr); ok {
+				writes[sel] = true
+			}
+		case *ast.UnaryExpr:
+			if sel, ok := ast.Unparen(n.X).(*ast.SelectorExpr); ok && n.Op == token.AND {
+				writes[sel] = true
+			}
+		case *ast.CallExpr:
+			c.acquisitions(n, acquired, atomicReads)
+		}
+		return true
+	})
+
+	needs := make(map[string]*need)
+	var order []string
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		s := info.Selections[sel]
+		if s == nil || s.Kind() != types.FieldVal || len(s.Index()) != 1 {
+			return true
+		}
+		var gf guardFact
+		if !c.pass.ImportObjectFact(s.Obj().(*types.Var).Origin(), &gf) || !params[rootObj(info, sel.X)] {
+			return true
+		}
+		if gf.Atomic && atomicReads[sel] {
+			return true
+		}
+		st, ok := annotation.Deref(s.Recv()).Underlying().(*types.Struct)
+		if !ok {
+			return true
+		}
+		base := types.ExprString(ast.Unparen(sel.X))
+		for _, mu := range gf.Mutexes {
+			path := base + "." + mu
+			if end, ok := acquired[path]; ok && end <= sel.Pos() {
+				continue
+			}
+			nd := needs[path]
+			if nd == nil {
+				nd = &need{path: path, rw: annotation.IsRWMutex(fieldType(st, mu))}
+				needs[path] = nd
+				order = append(order, path)
+			}
+			nd.write = nd.write || writes[sel]
+			field := base + "." + sel.Sel.Name
+			if !slices.Contains(nd.fields, field) {
+				nd.fields = append(nd.fields, field)
+			}
+		}
+		return true
+	})
+
+	var missing []*need
+	for _, path := range order {
+		nd := needs[path]
+		switch asserted[path] {
+		case assertExclusive:
+			continue
+		case assertRead:
+			if !nd.write {
+				continue
+			}
+		}
+		missing = append(missing, nd)
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	var paths, fields, calls []string
+	pkgName, importEdit := c.mutexasserts(file)
+	for _, nd := range missing {
+		paths = append(paths, nd.path)
+		for _, f := range nd.fields {
+			if !slices.Contains(fields, f) {
+				fields = append(fields, f)
+			}
+		}
+		calls = append(calls, fmt.Sprintf("%s.%s(&%s)", pkgName, assertFunc(nd), nd.path))
+	}
+	// Before the first statement rather than after the brace, so that a
+	// comment on the signature line stays there.
+	first := fd.Body.List[0].Pos()
+	edits := []analysis.TextEdit{{
+		Pos:     first,
+		End:     first,
+		NewText: []byte(strings.Join(calls, "\n\t") + "\n\t"),
+	}}
+	if importEdit != nil {
+		edits = append(edits, *importEdit)
+	}
+	c.pass.Report(analysis.Diagnostic{
+		Pos: fd.Name.Pos(),
+		Message: fmt.Sprintf("+checklocksignore function %s does not assert %s held at entry (accesses %s)",
+			fd.Name.Name, strings.Join(paths, ", "), strings.Join(fields, ", ")),
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   "Add " + strings.Join(calls, ", "),
+			TextEdits: edits,
+		}},
+	})
+}
+
+func assertFunc(nd *need) string {
+	switch {
+	case !nd.rw:
+		return "AssertMutexLocked"
+	case nd.write:
+		return "AssertRWMutexLocked"
+	default:
+		return "AssertRWMutexRLocked"
+	}
+}
+
+// assertion returns the lock asserted by a mutexasserts or lockassert call.
+func (c *checker) assertion(e ast.Expr) (string, int) {
+	call, ok := e.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return "", assertNone
+	}
+	fn := typeutil.StaticCallee(c.pass.TypesInfo, call)
+	if fn == nil || fn.Pkg() == nil {
+		return "", assertNone
+	}
+	u, ok := ast.Unparen(call.Args[0]).(*ast.UnaryExpr)
+	if !ok || u.Op != token.AND {
+		return "", assertNone
+	}
+	path := types.ExprString(ast.Unparen(u.X))
+	pkg := fn.Pkg().Path()
+	switch {
+	case pkg == mutexassertsPath:
+		switch fn.Name() {
+		case "AssertMutexLocked", "AssertRWMutexLocked":
+			return path, assertExclusive
+		case "AssertRWMutexRLocked":
+			return path, assertRead
+		}
+	case strings.HasSuffix(pkg, "/internal/lockassert"):
+		switch fn.Name() {
+		case "Held", "WHeld":
+			return path, assertExclusive
+		case "RHeld":
+			return path, assertRead
+		}
+	}
+	return "", assertNone
+}
+
+// acquisitions records in acquired the locks call acquires, at the end of
+// the call unless an earlier one acquired them first, and in atomicReads the
+// field of a sync/atomic load.
+func (c *checker) acquisitions(call *ast.CallExpr, acquired map[string]token.Pos, atomicReads map[*ast.SelectorExpr]bool) {
+	info := c.pass.TypesInfo
+	fn := typeutil.StaticCallee(info, call)
+	if fn == nil {
+		return
+	}
+	if _, kind := c.assertion(call); kind != assertNone {
+		return
+	}
+	if fn.Pkg() != nil && fn.Pkg().Path() == "sync/atomic" && strings.HasPrefix(fn.Name(), "Load") && len(call.Args) == 1 {
+		if u, ok := ast.Unparen(call.Args[0]).(*ast.UnaryExpr); ok && u.Op == token.AND {
+			if sel, ok := ast.Unparen(u.X).(*ast.SelectorExpr); ok {
+				atomicReads[sel] = true
+			}
+		}
+		return
+	}
+	acquire := func(path string) {
+		if _, ok := acquired[path]; !ok {
+			acquired[path] = call.End()
+		}
+	}
+	sel, isSel := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	sig := fn.Signature()
+	if recv := sig.Recv(); recv != nil && isSel && annotation.IsMutex(annotation.Deref(recv.Type())) {
+		switch fn.Name() {
+		case "Lock", "RLock", "TryLock", "TryRLock":
+			acquire(types.ExprString(ast.Unparen(sel.X)))
+		}
+		return
+	}
+	if slices.Contains(lockingHelpers, fn.Name()) {
+		for _, a := range call.Args {
+			if u, ok := ast.Unparen(a).(*ast.UnaryExpr); ok && u.Op == token.AND && annotation.IsMutex(info.TypeOf(u.X)) {
+				acquire(types.ExprString(ast.Unparen(u.X)))
+			}
+		}
+		return
+	}
+	// Substitute the call's receiver and arguments for the roots of the
+	// callee's +checklocksacquire paths, like pr.mu or a mutex parameter mu.
+	roots := make(map[string]ast.Expr)
+	if recv := sig.Recv(); recv != nil && isSel {
+		roots[recv.Name()] = sel.X
+	}
+	for i := range min(sig.Params().Len(), len(call.Args)) {
+		roots[sig.Params().At(i).Name()] = call.Args[i]
+	}
+	for _, path := range c.acquires[fn.Origin()] {
+		root, rest, _ := strings.Cut(path, ".")
+		arg, ok := roots[root]
+		if !ok {
+			continue
+		}
+		arg = ast.Unparen(arg)
+		if u, ok := arg.(*ast.UnaryExpr); ok && u.Op == token.AND {
+			arg = ast.Unparen(u.X)
+		}
+		lock := types.ExprString(arg)
+		if rest != "" {
+			lock += "." + rest
+		}
+		acquire(lock)
+	}
+}
+
+// mutexasserts returns the name under which file imports go-mutexasserts
+// and, if it does not, an edit adding the import.
+func (c *checker) mutexasserts(file *ast.File) (string, *analysis.TextEdit) {
+	for _, is := range file.Imports {
+		if path, _ := strconv.Unquote(is.Path.Value); path == mutexassertsPath {
+			if is.Name != nil {
+				return is.Name.Name, nil
+			}
+			return "mutexasserts", nil
+		}
+	}
+	for _, d := range file.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		if gd.Lparen.IsValid() {
+			// A group of its own, after the existing ones.
+			return "mutexasserts", &analysis.TextEdit{
+				Pos:     gd.Rparen,
+				End:     gd.Rparen,
+				NewText: []byte("\n\t" + strconv.Quote(mutexassertsPath) + "\n"),
+			}
+		}
+		is := gd.Specs[0].(*ast.ImportSpec)
+		spec := is.Path.Value
+		if is.Name != nil {
+			spec = is.Name.Name + " " + spec
+		}
+		return "mutexasserts", &analysis.TextEdit{
+			Pos:     gd.Pos(),
+			End:     gd.End(),
+			NewText: []byte("import (\n\t" + spec + "\n\n\t" + strconv.Quote(mutexassertsPath) + "\n)"),
+		}
+	}
+	return "mutexasserts", &analysis.TextEdit{
+		Pos:     file.Name.End(),
+		End:     file.Name.End(),
+		NewText: []byte("\n\nimport " + strconv.Quote(mutexassertsPath)),
+	}
+}
+
+// rootObj returns the variable at the root of a selector chain such as
+// pr.inner.
+func rootObj(info *types.Info, e ast.Expr) types.Object {
+	for {
+		switch x := ast.Unparen(e).(type) {
+		case *ast.SelectorExpr:
+			e = x.X
+		case *ast.StarExpr:
+			e = x.X
+		case *ast.Ident:
+			return info.Uses[x]
+		default:
+			return nil
+		}
+	}
+}
+
+func fieldType(st *types.Struct, name string) types.Type {
+	for i := range st.NumFields() {
+		if f := st.Field(i); f.Name() == name {
+			return f.Type()
+		}
+	}
+	return nil
+}
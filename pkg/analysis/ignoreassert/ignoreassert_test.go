@@ -0,0 +1,13 @@
+package ignoreassert_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/kakkoyun/checklocks-demo/pkg/analysis/ignoreassert"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), ignoreassert.Analyzer, "ignore", "noimport")
+}
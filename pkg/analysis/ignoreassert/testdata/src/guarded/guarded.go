@@ -0,0 +1,9 @@
+package guarded
+
+import "sync"
+
+type Counter struct {
+	Mu sync.Mutex
+	// +checklocks:Mu
+	N int
+}
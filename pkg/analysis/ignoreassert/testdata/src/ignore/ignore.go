@@ -0,0 +1,164 @@
+package ignore
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/trailofbits/go-mutexasserts"
+
+	"guarded"
+	"ignore/internal/lockassert"
+)
+
+type R struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	value int // want value:`guarded\(mu\)`
+
+	rw sync.RWMutex
+	// +checklocks:rw
+	read int // want read:`guarded\(rw\)`
+
+	// +checkatomic
+	// +checklocks:mu
+	mixed int32 // want mixed:`guarded\(mu\) atomic`
+
+	// +checkatomic
+	counter int32
+
+	id string
+}
+
+// +checklocksignore
+func (r *R) noAssert() { // want `\+checklocksignore function noAssert does not assert r.mu held at entry \(accesses r.value\)`
+	r.value = 1
+}
+
+// +checklocksignore
+func (r *R) asserted() {
+	mutexasserts.AssertMutexLocked(&r.mu)
+	r.value = 2
+}
+
+// +checklocksignore
+func (r *R) assertedByLockassert() {
+	lockassert.Held(&r.mu)
+	lockassert.RHeld(&r.rw)
+	r.value = r.read
+}
+
+// +checklocksignore
+func (r *R) assertedTooLate() { // want `does not assert r.mu held at entry`
+	r.value = 3
+	mutexasserts.AssertMutexLocked(&r.mu)
+}
+
+// +checklocksignore
+func (r *R) readOnly() int { // want `does not assert r.rw held at entry \(accesses r.read\)`
+	return r.read
+}
+
+// +checklocksignore
+func (r *R) readAssertedForWrite() { // want `does not assert r.rw held at entry`
+	mutexasserts.AssertRWMutexRLocked(&r.rw)
+	r.read++
+}
+
+// +checklocksignore
+func (r *R) two(o *R) { // want `does not assert r.mu, o.rw held at entry \(accesses r.value, o.read\)`
+	r.value = o.read
+}
+
+// +checklocksignore
+func (r *R) locksItself() {
+	r.mu.Lock()
+	r.value = 4
+	r.mu.Unlock()
+}
+
+// +checklocksacquire:r.mu
+func (r *R) lockMu() { r.mu.Lock() }
+
+// +checklocksignore
+func (r *R) locksThroughHelper() {
+	r.lockMu()
+	r.value = 5
+}
+
+// +checklocksacquire:mu
+func lockWith(mu *sync.Mutex) { mu.Lock() }
+
+// +checklocksignore
+func (r *R) locksElsewhere() {
+	lockWith(&r.mu)
+	r.value = 6
+}
+
+// lockCtx is named like the resource helper that acquires mu only on
+// success.
+func (r *R) lockCtx(mu *sync.Mutex) error { mu.Lock(); return nil }
+
+// +checklocksignore
+func (r *R) locksConditionally() {
+	if r.lockCtx(&r.mu) == nil {
+		r.value = 7
+	}
+}
+
+// +checklocksignore
+func (r *R) accessBeforeLock() { // want `does not assert r.mu held at entry \(accesses r.value\)`
+	r.value = 8
+	r.mu.Lock()
+	r.value = 9
+	r.mu.Unlock()
+}
+
+func touch(mu *sync.Mutex) {}
+
+// +checklocksignore
+func (r *R) passesUnannotated() { // want `does not assert r.mu held at entry \(accesses r.value\)`
+	touch(&r.mu)
+	r.value = 10
+}
+
+// +checklocksignore
+func (r *R) atomics() int32 {
+	atomic.AddInt32(&r.counter, 1)
+	return atomic.LoadInt32(&r.mixed)
+}
+
+// +checklocksignore
+func (r *R) mixedWrite() { // want `does not assert r.mu held at entry \(accesses r.mixed\)`
+	atomic.StoreInt32(&r.mixed, 1)
+}
+
+// +checklocksignore
+func (r *R) unguarded() string {
+	return r.id
+}
+
+// +checklocksignore
+func local() {
+	r := &R{}
+	r.value = 7
+}
+
+// +checklocksignore
+func imported(c *guarded.Counter) { // want `does not assert c.Mu held at entry \(accesses c.N\)`
+	c.N++
+}
+
+type G[T any] struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	v T // want v:`guarded\(mu\)`
+}
+
+// +checklocksignore
+func (g *G[T]) set(v T) { // want `does not assert g.mu held at entry \(accesses g.v\)`
+	g.v = v
+}
+
+func (r *R) notIgnored() {
+	r.value = 8
+}
@@ -0,0 +1,7 @@
+package lockassert
+
+import "sync"
+
+func Held(m *sync.Mutex)     {}
+func WHeld(rw *sync.RWMutex) {}
+func RHeld(rw *sync.RWMutex) {}
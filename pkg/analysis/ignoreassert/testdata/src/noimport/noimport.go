@@ -0,0 +1,14 @@
+package noimport
+
+import "sync"
+
+type R struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	v int // want v:`guarded\(mu\)`
+}
+
+// +checklocksignore
+func (r *R) set() { // want `\+checklocksignore function set does not assert r.mu held at entry \(accesses r.v\)`
+	r.v = 1
+}
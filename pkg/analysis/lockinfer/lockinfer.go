@@ -0,0 +1,519 @@
+// Package lockinfer defines an analyzer that proposes checklocks
+// annotations for code that does not have them yet.
+//
+// For every unannotated field of a struct with a sync.Mutex or
+// sync.RWMutex sibling, it counts how often the field is accessed with that
+// mutex held, how often it is accessed through sync/atomic, and proposes
+//
+//	// +checklocks:mu      accessed with mu held
+//	// +checkatomic        accessed only through sync/atomic
+//	// +checkatomic and +checklocks:mu together for atomic fields that are
+//	// only written with mu held
+//
+// For unexported methods that never take a lock themselves and are only
+// called with one of their receiver's mutexes held, as setDataLocked is, it
+// proposes a function-level +checklocks:pr.mu, or +checklocksread:pr.rwMu
+// when some callers hold only the read lock. Inferred function
+// preconditions count as held when scoring the fields those helpers touch.
+//
+// Each proposal carries a confidence, the fraction of accesses or call sites
+// consistent with it, and a suggested fix that inserts the annotation, so
+// `lockinfer -fix ./...` applies every proposal above -min-confidence.
+//
+// The analysis is path-insensitive, like checklocks' own preconditions: a
+// lock counts as held only if it is held on every path reaching the access.
+// Test files, functions marked +checklocksignore and accesses through a
+// variable that was just assigned a composite literal (a value that is not
+// shared yet) are not counted.
+package lockinfer
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/kakkoyun/checklocks-demo/internal/annotation"
+)
+
+// Analyzer proposes checklocks annotations inferred from lock usage.
+var Analyzer = &analysis.Analyzer{
+	Name: "lockinfer",
+	Doc:  "propose +checklocks, +checklocksread and +checkatomic annotations inferred from how fields and helpers are used under locks",
+	URL:  "https://pkg.go.dev/github.com/kakkoyun/checklocks-demo/pkg/analysis/lockinfer",
+	Run:  run,
+}
+
+var (
+	minConfidence = 0.8
+	minAccesses   = 2
+)
+
+func init() {
+	Analyzer.Flags.Float64Var(&minConfidence, "min-confidence", minConfidence, "only propose annotations with at least this confidence, from 0 to 1")
+	Analyzer.Flags.IntVar(&minAccesses, "min-accesses", minAccesses, "only propose field annotations for fields accessed at least this many times")
+}
+
+// accessKind classifies one access to a field.
+type accessKind int
+
+const (
+	read accessKind = iota
+	write
+	atomicRead
+	atomicWrite
+	numKinds
+)
+
+type counts [numKinds]int
+
+func (c counts) total() int  { return c[read] + c[write] + c[atomicRead] + c[atomicWrite] }
+func (c counts) atomic() int { return c[atomicRead] + c[atomicWrite] }
+func (c counts) writes() int { return c[write] + c[atomicWrite] }
+
+// fieldStats accumulates the accesses to one field.
+type fieldStats struct {
+	all      counts
+	held     map[string]*counts // By sibling mutex, held in either mode.
+	heldExcl map[string]*counts // By sibling mutex, held exclusively.
+}
+
+// funcInfo accumulates what is known about one function declared in the
+// package.
+type funcInfo struct {
+	decl     *ast.FuncDecl
+	sites    int             // Static call sites through a receiver.
+	held     map[string]int  // Receiver mutex -> call sites holding it.
+	heldExcl map[string]int  // Receiver mutex -> call sites holding it exclusively.
+	locks    map[string]bool // Lock paths the function takes itself.
+	touches  bool            // Accesses a non-mutex field of its receiver.
+	escapes  bool            // Used as a method value.
+	inferred map[string]bool // Inferred preconditions: path -> exclusive.
+}
+
+// funcAnnotation is a checklocks function annotation, such as
+// +checklocks:pr.mu.
+type funcAnnotation struct {
+	kind, path string
+}
+
+type checker struct {
+	pass   *analysis.Pass
+	decls  map[*types.Func]*ast.FuncDecl
+	funcs  map[*types.Func]*funcInfo
+	fields map[*types.Var]*fieldStats
+	// fieldDecls lists the unannotated candidate fields in declaration
+	// order; fieldOwner maps each to the struct type declaring it.
+	fieldDecls []candidate
+	fieldOwner map[*types.Var]*types.Named
+}
+
+type candidate struct {
+	field *types.Var
+	ident *ast.Ident
+	multi bool // Declared with other names, as in "a, b int".
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	c := &checker{
+		pass:       pass,
+		decls:      make(map[*types.Func]*ast.FuncDecl),
+		funcs:      make(map[*types.Func]*funcInfo),
+		fields:     make(map[*types.Var]*fieldStats),
+		fieldOwner: make(map[*types.Var]*types.Named),
+	}
+	var decls []*ast.FuncDecl
+	for _, file := range pass.Files {
+		test := strings.HasSuffix(pass.Fset.File(file.Pos()).Name(), "_test.go")
+		for _, d := range file.Decls {
+			switch d := d.(type) {
+			case *ast.GenDecl:
+				if !test {
+					c.collectFields(d)
+				}
+			case *ast.FuncDecl:
+				fn, ok := pass.TypesInfo.Defs[d.Name].(*types.Func)
+				if !ok || d.Body == nil {
+					continue
+				}
+				c.decls[fn] = d
+				if !test && !annotation.Has(d.Doc, "+checklocksignore") {
+					decls = append(decls, d)
+				}
+			}
+		}
+	}
+
+	// First find helpers only called under a lock, then score fields with
+	// those helpers' preconditions in place.
+	for _, fd := range decls {
+		c.walkFunc(fd, c.info(c.funcOf(fd)))
+	}
+	for _, fd := range decls {
+		c.inferFunc(fd)
+	}
+	c.fields = make(map[*types.Var]*fieldStats)
+	for _, fd := range decls {
+		c.walkFunc(fd, nil)
+	}
+	for _, cand := range c.fieldDecls {
+		c.inferField(cand)
+	}
+	return nil, nil
+}
+
+// collectFields records the fields of struct types that have mutex
+// siblings and carry no lock annotation yet.
+func (c *checker) collectFields(gd *ast.GenDecl) {
+	if gd.Tok != token.TYPE {
+		return
+	}
+	for _, spec := range gd.Specs {
+		ts := spec.(*ast.TypeSpec)
+		st, ok := ts.Type.(*ast.StructType)
+		if !ok {
+			continue
+		}
+		obj, ok := c.pass.TypesInfo.Defs[ts.Name].(*types.TypeName)
+		if !ok {
+			continue
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok || len(mutexes(named)) == 0 {
+			continue
+		}
+		for _, f := range st.Fields.List {
+			if annotated(f) {
+				continue
+			}
+			for _, name := range f.Names {
+				v, ok := c.pass.TypesInfo.Defs[name].(*types.Var)
+				if !ok || annotation.IsMutex(v.Type()) || isSyncType(v.Type()) {
+					continue
+				}
+				c.fieldOwner[v] = named
+				c.fieldDecls = append(c.fieldDecls, candidate{field: v, ident: name, multi: len(f.Names) > 1})
+			}
+		}
+	}
+}
+
+// mutexes returns the names of named's mutex fields.
+func mutexes(named *types.Named) []string {
+	st, ok := named.Origin().Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	var out []string
+	for i := range st.NumFields() {
+		if f := st.Field(i); annotation.IsMutex(f.Type()) {
+			out = append(out, f.Name())
+		}
+	}
+	return out
+}
+
+func (c *checker) funcOf(fd *ast.FuncDecl) *types.Func {
+	return c.pass.TypesInfo.Defs[fd.Name].(*types.Func)
+}
+
+func (c *checker) info(fn *types.Func) *funcInfo {
+	info := c.funcs[fn]
+	if info == nil {
+		info = &funcInfo{
+			decl:     c.decls[fn],
+			held:     make(map[string]int),
+			heldExcl: make(map[string]int),
+			locks:    make(map[string]bool),
+		}
+		c.funcs[fn] = info
+	}
+	return info
+}
+
+// access records an access of kind to the field selected by sel, and
+// reports whether the field is one being scored.
+func (w *walker) access(sel *ast.SelectorExpr, held heldSet, kind accessKind) bool {
+	s := w.c.pass.TypesInfo.Selections[sel]
+	if s == nil || s.Kind() != types.FieldVal || len(s.Index()) != 1 {
+		return false
+	}
+	named := namedOf(s.Recv())
+	if named == nil {
+		return false
+	}
+	named = named.Origin()
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
+	field := st.Field(s.Index()[0])
+	base := types.ExprString(ast.Unparen(sel.X))
+	if w.fn != nil && w.fn.decl != nil && base == recvName(w.fn.decl) && !annotation.IsMutex(field.Type()) {
+		w.fn.touches = true
+	}
+	if w.c.fieldOwner[field] == nil {
+		return false
+	}
+	if w.fresh[base] {
+		return true
+	}
+	fs := w.c.fields[field]
+	if fs == nil {
+		fs = &fieldStats{held: make(map[string]*counts), heldExcl: make(map[string]*counts)}
+		w.c.fields[field] = fs
+	}
+	fs.all[kind]++
+	for _, m := range mutexes(named) {
+		excl, ok := held[base+"."+m]
+		if !ok {
+			continue
+		}
+		bump(fs.held, m, kind)
+		if excl {
+			bump(fs.heldExcl, m, kind)
+		}
+	}
+	return true
+}
+
+func bump(m map[string]*counts, key string, kind accessKind) {
+	if m[key] == nil {
+		m[key] = new(counts)
+	}
+	m[key][kind]++
+}
+
+// callSite records a call to the method fn through recv with held.
+func (c *checker) callSite(fn *types.Func, recv ast.Expr, held heldSet) {
+	named := namedOf(fn.Signature().Recv().Type())
+	if named == nil {
+		return
+	}
+	info := c.info(fn)
+	info.sites++
+	base := types.ExprString(ast.Unparen(recv))
+	for _, m := range mutexes(named) {
+		excl, ok := held[base+"."+m]
+		if !ok {
+			continue
+		}
+		info.held[m]++
+		if excl {
+			info.heldExcl[m]++
+		}
+	}
+}
+
+// annotations returns fd's checklocks function annotations.
+func (c *checker) annotations(fd *ast.FuncDecl) []funcAnnotation {
+	var out []funcAnnotation
+	for _, text := range annotation.List(fd.Doc) {
+		kind, path, ok := strings.Cut(text, ":")
+		if ok && strings.HasPrefix(kind, "+checklocks") {
+			out = append(out, funcAnnotation{kind, path})
+		}
+	}
+	return out
+}
+
+// preconditions returns the locks held on entry to fd: its annotated
+// preconditions and, once inferred, those of info.
+func (c *checker) preconditions(fd *ast.FuncDecl, info *funcInfo) map[string]bool {
+	held := make(map[string]bool)
+	for _, a := range c.annotations(fd) {
+		switch a.kind {
+		case "+checklocks", "+checklocksrelease":
+			held[a.path] = true
+		case "+checklocksread", "+checklocksreleaseread":
+			held[a.path] = false
+		}
+	}
+	if info == nil {
+		info = c.funcs[c.funcOf(fd)]
+	}
+	if info != nil {
+		for path, excl := range info.inferred {
+			held[path] = excl
+		}
+	}
+	return held
+}
+
+// inferFunc proposes a precondition for fd if it is an unexported method
+// that is only called with one of its receiver's mutexes held.
+func (c *checker) inferFunc(fd *ast.FuncDecl) {
+	fn := c.funcOf(fd)
+	info := c.funcs[fn]
+	recv := recvName(fd)
+	if info == nil || info.sites == 0 || info.escapes || !info.touches || recv == "" || fn.Exported() ||
+		len(c.annotations(fd)) > 0 {
+		return
+	}
+	named := namedOf(fn.Signature().Recv().Type())
+	if named == nil {
+		return
+	}
+	var lines, reasons []string
+	conf := 1.0
+	for _, m := range mutexes(named) {
+		path := recv + "." + m
+		if info.locks[path] || info.held[m] == 0 {
+			continue
+		}
+		score := float64(info.held[m]) / float64(info.sites)
+		if score < minConfidence {
+			continue
+		}
+		kind := "+checklocks"
+		if info.heldExcl[m] < info.held[m] {
+			kind = "+checklocksread"
+		}
+		if info.inferred == nil {
+			info.inferred = make(map[string]bool)
+		}
+		info.inferred[path] = kind == "+checklocks"
+		lines = append(lines, kind+":"+path)
+		reasons = append(reasons, fmt.Sprintf("called with %s held at %d of %d call sites", path, info.held[m], info.sites))
+		conf = min(conf, score)
+	}
+	if len(lines) > 0 {
+		c.propose(fd.Name, fd.Type.Func, "", fd.Name.Name, lines, reasons, conf)
+	}
+}
+
+// inferField proposes annotations for one field from its access counts.
+func (c *checker) inferField(cand candidate) {
+	fs := c.fields[cand.field]
+	if fs == nil || fs.all.total() < minAccesses {
+		return
+	}
+	total := fs.all.total()
+	var lines, reasons []string
+	conf := 1.0
+	if score := float64(fs.all.atomic()) / float64(total); score >= minConfidence {
+		lines = append(lines, "+checkatomic")
+		reasons = append(reasons, fmt.Sprintf("accessed atomically in %d of %d places", fs.all.atomic(), total))
+		conf = score
+		// Mixed mode: atomic everywhere, but only written under a lock.
+		if writes := fs.all.writes(); writes > 0 {
+			if m, n := best(fs.heldExcl, counts.writes); n > 0 {
+				if score := float64(n) / float64(writes); score >= minConfidence {
+					lines = append(lines, "+checklocks:"+m)
+					reasons = append(reasons, fmt.Sprintf("written with %s held in %d of %d", m, n, writes))
+					conf = min(conf, score)
+				}
+			}
+		}
+	} else if m, n := best(fs.held, counts.total); n > 0 {
+		if score := float64(n) / float64(total); score >= minConfidence {
+			lines = append(lines, "+checklocks:"+m)
+			reasons = append(reasons, fmt.Sprintf("accessed with %s held in %d of %d places", m, n, total))
+			conf = score
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	// An annotation above "a, b int" would cover both names.
+	fixAt := cand.ident.Pos()
+	if cand.multi {
+		fixAt = token.NoPos
+	}
+	c.propose(cand.ident, fixAt, c.indentation(cand.ident.Pos()), cand.ident.Name, lines, reasons, conf)
+}
+
+// best returns the mutex with the highest count under measure.
+func best(m map[string]*counts, measure func(counts) int) (string, int) {
+	name, n := "", 0
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		if v := measure(*m[k]); v > n {
+			name, n = k, v
+		}
+	}
+	return name, n
+}
+
+// propose reports the annotation lines for name at node with a fix that
+// inserts them, indented by indent, above the line containing fixAt. There
+// is no fix if fixAt is token.NoPos.
+func (c *checker) propose(node ast.Node, fixAt token.Pos, indent, name string, lines, reasons []string, conf float64) {
+	d := analysis.Diagnostic{
+		Pos: node.Pos(),
+		End: node.End(),
+		Message: fmt.Sprintf("%s for %s: %s (confidence %d%%)",
+			strings.Join(lines, ", "), name, strings.Join(reasons, ", "), int(conf*100)),
+	}
+	if fixAt.IsValid() {
+		tf := c.pass.Fset.File(fixAt)
+		start := tf.LineStart(tf.Line(fixAt))
+		var text bytes.Buffer
+		for _, l := range lines {
+			fmt.Fprintf(&text, "%s// %s\n", indent, l)
+		}
+		d.SuggestedFixes = []analysis.SuggestedFix{{
+			Message:   "Add " + strings.Join(lines, ", "),
+			TextEdits: []analysis.TextEdit{{Pos: start, End: start, NewText: text.Bytes()}},
+		}}
+	}
+	c.pass.Report(d)
+}
+
+// indentation returns the leading whitespace of the line containing pos.
+func (c *checker) indentation(pos token.Pos) string {
+	tf := c.pass.Fset.File(pos)
+	src, err := c.pass.ReadFile(tf.Name())
+	if err != nil {
+		return ""
+	}
+	start := tf.Offset(tf.LineStart(tf.Line(pos)))
+	end := start
+	for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	return string(src[start:end])
+}
+
+// annotated reports whether a struct field already carries a checklocks
+// annotation.
+func annotated(f *ast.Field) bool {
+	for _, text := range annotation.List(f.Doc, f.Comment) {
+		if strings.HasPrefix(text, "+checklocks") || strings.HasPrefix(text, "+checkatomic") {
+			return true
+		}
+	}
+	return false
+}
+
+func recvName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 || len(fd.Recv.List[0].Names) == 0 {
+		return ""
+	}
+	return fd.Recv.List[0].Names[0].Name
+}
+
+// namedOf returns the named type of t or *t.
+func namedOf(t types.Type) *types.Named {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	n, _ := t.(*types.Named)
+	return n
+}
+
+// isSyncType reports whether t is a type from sync or sync/atomic, which
+// synchronizes itself and needs no annotation.
+func isSyncType(t types.Type) bool {
+	n := namedOf(t)
+	if n == nil || n.Obj().Pkg() == nil {
+		return false
+	}
+	path := n.Obj().Pkg().Path()
+	return path == "sync" || path == "sync/atomic"
+}
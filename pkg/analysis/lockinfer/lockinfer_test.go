@@ -0,0 +1,13 @@
+package lockinfer_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/kakkoyun/checklocks-demo/pkg/analysis/lockinfer"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), lockinfer.Analyzer, "infer")
+}
@@ -0,0 +1,163 @@
+package infer
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type Counter struct {
+	mu    sync.Mutex
+	value int // want `\+checklocks:mu for value: accessed with mu held in 4 of 4 places \(confidence 100%\)`
+	name  string
+	hits  int32 // want `\+checkatomic for hits: accessed atomically in 2 of 2 places \(confidence 100%\)`
+	// mixed is written under mu but read without it.
+	mixed  int32 // want `\+checkatomic, \+checklocks:mu for mixed: accessed atomically in 2 of 2 places, written with mu held in 1 of 1 \(confidence 100%\)`
+	mostly int
+
+	// +checklocks:mu
+	annotated int
+
+	rw    sync.RWMutex
+	cache map[string]int // want `\+checklocks:rw for cache`
+}
+
+func NewCounter() *Counter {
+	c := &Counter{}
+	c.value = 1 // Not shared yet.
+	c.cache = make(map[string]int)
+	return c
+}
+
+func (c *Counter) Inc() {
+	c.mu.Lock()
+	c.value++
+	c.mostly++
+	c.annotated++
+	c.mu.Unlock()
+}
+
+func (c *Counter) Get() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mostly--
+	return c.value
+}
+
+func (c *Counter) Set(v int) {
+	c.mu.Lock()
+	c.setLocked(v)
+	c.mu.Unlock()
+}
+
+func (c *Counter) setLocked(v int) { // want `\+checklocks:c.mu for setLocked: called with c.mu held at 1 of 1 call sites \(confidence 100%\)`
+	c.value = v
+}
+
+// Maybe only locks on one path, so mostly is not held when written.
+func (c *Counter) Maybe(lock bool) {
+	if lock {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	c.mostly = 0
+}
+
+func (c *Counter) Name() string        { return c.name }
+func (c *Counter) SetName(name string) { c.name = name }
+
+func (c *Counter) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f := c.resetLocked // Escapes, so its callers are unknown.
+	f()
+}
+
+func (c *Counter) resetLocked() { c.name = "" }
+
+func (c *Counter) Hit() int32 {
+	atomic.AddInt32(&c.hits, 1)
+	return atomic.LoadInt32(&c.hits)
+}
+
+func (c *Counter) SetMixed(v int32) {
+	c.mu.Lock()
+	atomic.StoreInt32(&c.mixed, v)
+	c.mu.Unlock()
+}
+
+func (c *Counter) Mixed() int32 { return atomic.LoadInt32(&c.mixed) }
+
+func (c *Counter) Lookup(k string) int {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+	return c.lookupLocked(k)
+}
+
+// lookupLocked reads the cache.
+func (c *Counter) lookupLocked(k string) int { // want `\+checklocksread:c.rw for lookupLocked: called with c.rw held at 1 of 1 call sites`
+	return c.cache[k]
+}
+
+func (c *Counter) Store(k string, v int) {
+	c.rw.Lock()
+	c.cache[k] = v
+	c.rw.Unlock()
+}
+
+// Exported methods may be called from elsewhere, so nothing is inferred.
+func (c *Counter) ValueLocked() int { return c.annotated }
+
+// Callers that use annotated helpers are understood.
+
+// +checklocksacquire:c.mu
+func (c *Counter) lock() { c.mu.Lock() }
+
+// +checklocksrelease:c.mu
+func (c *Counter) unlock() { c.mu.Unlock() }
+
+func (c *Counter) Dec() {
+	c.lock()
+	c.value--
+	c.unlock()
+}
+
+type Pair struct {
+	mu   sync.Mutex
+	a, b int // want `\+checklocks:mu for a` `\+checklocks:mu for b`
+}
+
+func (p *Pair) Swap() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.a, p.b = p.b, p.a
+}
+
+// Generic receivers and instantiations resolve to the same fields and
+// methods.
+type Box[T any] struct {
+	mu  sync.Mutex
+	val T // want `\+checklocks:mu for val: accessed with mu held in 3 of 3 places`
+}
+
+func (b *Box[T]) Put(v T) {
+	b.mu.Lock()
+	b.put(v)
+	b.mu.Unlock()
+}
+
+func (b *Box[T]) put(v T) { // want `\+checklocks:b.mu for put: called with b.mu held at 2 of 2 call sites`
+	b.val = v
+}
+
+func (b *Box[T]) Get() T {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.val
+}
+
+func Reset(b *Box[int]) {
+	b.mu.Lock()
+	b.put(0)
+	b.val++
+	b.mu.Unlock()
+}
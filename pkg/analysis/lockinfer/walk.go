@@ -0,0 +1,393 @@
+package lockinfer
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"maps"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/kakkoyun/checklocks-demo/internal/annotation"
+)
+
+// heldSet maps lock paths such as "pr.mu" that must be held to whether they
+// are held exclusively.
+type heldSet map[string]bool
+
+func clone(h heldSet) heldSet {
+	out := make(heldSet, len(h))
+	maps.Copy(out, h)
+	return out
+}
+
+// intersect keeps the locks held on every path, exclusively only if
+// exclusively on every path.
+func intersect(paths []heldSet) heldSet {
+	out := clone(paths[0])
+	for _, h := range paths[1:] {
+		for k, excl := range out {
+			other, ok := h[k]
+			if !ok {
+				delete(out, k)
+			} else {
+				out[k] = excl && other
+			}
+		}
+	}
+	return out
+}
+
+// walker follows one function body in statement order, recording field
+// accesses and call sites against the locks that must be held there.
+type walker struct {
+	c     *checker
+	fn    *funcInfo       // Nil inside function literals and when scoring fields.
+	fresh map[string]bool // Variables holding a value that is not shared yet.
+}
+
+// walkFunc walks fd, seeding it with its annotated and inferred
+// preconditions, and every function literal in it with nothing held.
+func (c *checker) walkFunc(fd *ast.FuncDecl, info *funcInfo) {
+	held := heldSet{}
+	for path, excl := range c.preconditions(fd, info) {
+		held[path] = excl
+	}
+	w := &walker{c: c, fn: info, fresh: make(map[string]bool)}
+	w.stmts(fd.Body.List, held)
+	lits := &walker{c: c, fresh: make(map[string]bool)}
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.FuncLit); ok {
+			lits.stmts(lit.Body.List, heldSet{})
+		}
+		return true
+	})
+}
+
+func (w *walker) stmts(list []ast.Stmt, held heldSet) (heldSet, bool) {
+	for _, s := range list {
+		var done bool
+		held, done = w.stmt(s, held)
+		if done {
+			return held, true
+		}
+	}
+	return held, false
+}
+
+// stmt processes s and returns the locks that must be held after it, and
+// whether s never completes normally (return, panic or branch).
+func (w *walker) stmt(s ast.Stmt, held heldSet) (heldSet, bool) {
+	switch s := s.(type) {
+	case *ast.BlockStmt:
+		return w.stmts(s.List, held)
+	case *ast.LabeledStmt:
+		return w.stmt(s.Stmt, held)
+	case *ast.ReturnStmt:
+		for _, r := range s.Results {
+			w.expr(r, held)
+		}
+		return held, true
+	case *ast.BranchStmt:
+		return held, true
+	case *ast.ExprStmt:
+		w.expr(s.X, held)
+		if call, ok := s.X.(*ast.CallExpr); ok && isPanic(w.c.pass.TypesInfo, call) {
+			return held, true
+		}
+		return held, false
+	case *ast.AssignStmt:
+		for _, r := range s.Rhs {
+			w.expr(r, held)
+		}
+		for i, l := range s.Lhs {
+			w.lhs(l, held, s.Tok != token.DEFINE)
+			if id, ok := l.(*ast.Ident); ok {
+				w.fresh[id.Name] = len(s.Lhs) == len(s.Rhs) && isFresh(w.c.pass.TypesInfo, s.Rhs[i])
+			}
+		}
+		return held, false
+	case *ast.IncDecStmt:
+		w.lhs(s.X, held, true)
+		return held, false
+	case *ast.DeferStmt:
+		w.args(s.Call, held)
+		return held, false
+	case *ast.GoStmt:
+		w.args(s.Call, held)
+		return held, false
+	case *ast.IfStmt:
+		if s.Init != nil {
+			held, _ = w.stmt(s.Init, held)
+		}
+		w.expr(s.Cond, held)
+		var b branches
+		b.add(w.stmt(s.Body, clone(held)))
+		if s.Else != nil {
+			b.add(w.stmt(s.Else, clone(held)))
+		} else {
+			b.add(held, false)
+		}
+		return b.merge(held)
+	case *ast.ForStmt:
+		if s.Init != nil {
+			held, _ = w.stmt(s.Init, held)
+		}
+		w.expr(s.Cond, held)
+		body, _ := w.stmt(s.Body, clone(held))
+		if s.Post != nil {
+			w.stmt(s.Post, body)
+		}
+		return held, false
+	case *ast.RangeStmt:
+		w.expr(s.X, held)
+		w.stmt(s.Body, clone(held))
+		return held, false
+	case *ast.SwitchStmt:
+		if s.Init != nil {
+			held, _ = w.stmt(s.Init, held)
+		}
+		w.expr(s.Tag, held)
+		return w.clauses(s.Body, held)
+	case *ast.TypeSwitchStmt:
+		if s.Init != nil {
+			held, _ = w.stmt(s.Init, held)
+		}
+		held, _ = w.stmt(s.Assign, held)
+		return w.clauses(s.Body, held)
+	case *ast.SelectStmt:
+		var b branches
+		for _, cl := range s.Body.List {
+			cc := cl.(*ast.CommClause)
+			h := clone(held)
+			if cc.Comm != nil {
+				h, _ = w.stmt(cc.Comm, h)
+			}
+			b.add(w.stmts(cc.Body, h))
+		}
+		return b.merge(held)
+	default:
+		w.expr(s, held)
+		return held, false
+	}
+}
+
+// clauses handles the case clauses of a switch or type switch.
+func (w *walker) clauses(body *ast.BlockStmt, held heldSet) (heldSet, bool) {
+	var b branches
+	hasDefault := false
+	for _, cl := range body.List {
+		cc := cl.(*ast.CaseClause)
+		if cc.List == nil {
+			hasDefault = true
+		}
+		for _, e := range cc.List {
+			w.expr(e, held)
+		}
+		b.add(w.stmts(cc.Body, clone(held)))
+	}
+	if !hasDefault {
+		b.add(held, false)
+	}
+	return b.merge(held)
+}
+
+// branches accumulates the outcomes of alternative paths.
+type branches struct {
+	held []heldSet
+}
+
+func (b *branches) add(h heldSet, done bool) {
+	if !done {
+		b.held = append(b.held, h)
+	}
+}
+
+// merge intersects the paths that fall through; if none do, the statement
+// never completes normally.
+func (b *branches) merge(before heldSet) (heldSet, bool) {
+	if len(b.held) == 0 {
+		return before, true
+	}
+	return intersect(b.held), false
+}
+
+// lhs records an assignment to e, a write if it is a field selector.
+func (w *walker) lhs(e ast.Expr, held heldSet, assign bool) {
+	if sel, ok := ast.Unparen(e).(*ast.SelectorExpr); ok && assign {
+		if w.access(sel, held, write) {
+			w.expr(sel.X, held)
+			return
+		}
+	}
+	w.expr(e, held)
+}
+
+// expr processes the field accesses and calls in n in evaluation order.
+// Function literals are skipped; walkFunc walks them separately.
+func (w *walker) expr(n ast.Node, held heldSet) {
+	if n == nil {
+		return
+	}
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			w.call(n, held)
+			return false
+		case *ast.SelectorExpr:
+			if s := w.c.pass.TypesInfo.Selections[n]; s != nil && s.Kind() == types.MethodVal {
+				// A method value escapes: its call sites are unknown.
+				if fn, ok := s.Obj().(*types.Func); ok {
+					w.c.info(fn.Origin()).escapes = true
+				}
+			}
+			w.access(n, held, read)
+		}
+		return true
+	})
+}
+
+func (w *walker) args(call *ast.CallExpr, held heldSet) {
+	for _, a := range call.Args {
+		w.expr(a, held)
+	}
+}
+
+// call processes one call: its operands, then its effect on held.
+func (w *walker) call(call *ast.CallExpr, held heldSet) {
+	fn := typeutil.StaticCallee(w.c.pass.TypesInfo, call)
+	if fn != nil && fn.Pkg() != nil && fn.Pkg().Path() == "sync/atomic" && len(call.Args) > 0 {
+		// atomic.AddInt32(&pr.f, ...) is an atomic access to f.
+		if u, ok := ast.Unparen(call.Args[0]).(*ast.UnaryExpr); ok && u.Op == token.AND {
+			if sel, ok := ast.Unparen(u.X).(*ast.SelectorExpr); ok {
+				kind := atomicRead
+				if !strings.HasPrefix(fn.Name(), "Load") {
+					kind = atomicWrite
+				}
+				if w.access(sel, held, kind) {
+					w.expr(sel.X, held)
+					for _, a := range call.Args[1:] {
+						w.expr(a, held)
+					}
+					return
+				}
+			}
+		}
+	}
+
+	sel, isSel := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if isSel && fn != nil && fn.Signature().Recv() != nil {
+		w.expr(sel.X, held) // Not the method selector itself.
+	} else {
+		w.expr(call.Fun, held)
+	}
+	w.args(call, held)
+	if fn == nil {
+		return
+	}
+	fn = fn.Origin()
+
+	if recv := fn.Signature().Recv(); recv != nil && annotation.IsMutex(annotation.Deref(recv.Type())) && isSel {
+		path := types.ExprString(ast.Unparen(sel.X))
+		switch fn.Name() {
+		case "Lock":
+			held[path] = true
+		case "RLock":
+			if _, ok := held[path]; !ok {
+				held[path] = false
+			}
+		case "Unlock", "RUnlock":
+			delete(held, path)
+		}
+		if w.fn != nil && (fn.Name() == "Lock" || fn.Name() == "RLock") {
+			w.fn.locks[path] = true
+		}
+		return
+	}
+
+	callee := w.c.decls[fn]
+	if callee == nil {
+		return
+	}
+	if isSel && fn.Signature().Recv() != nil {
+		w.c.callSite(fn, sel.X, held)
+	}
+	// Translate the callee's lock annotations into the caller's terms.
+	for _, a := range w.c.annotations(callee) {
+		path, ok := w.c.translate(callee, call, a.path)
+		if !ok {
+			continue
+		}
+		switch a.kind {
+		case "+checklocksacquire":
+			held[path] = true
+		case "+checklocksacquireread":
+			held[path] = false
+		case "+checklocksrelease", "+checklocksreleaseread":
+			delete(held, path)
+		}
+	}
+}
+
+// translate rewrites an annotation path of callee, rooted at its receiver or
+// a parameter, into an expression path at call.
+func (c *checker) translate(callee *ast.FuncDecl, call *ast.CallExpr, path string) (string, bool) {
+	root, rest, ok := strings.Cut(path, ".")
+	if !ok {
+		return "", false
+	}
+	if callee.Recv != nil && len(callee.Recv.List) > 0 && len(callee.Recv.List[0].Names) > 0 &&
+		callee.Recv.List[0].Names[0].Name == root {
+		sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+		if !ok {
+			return "", false
+		}
+		return types.ExprString(ast.Unparen(sel.X)) + "." + rest, true
+	}
+	i := 0
+	for _, field := range callee.Type.Params.List {
+		for _, name := range field.Names {
+			if name.Name == root && i < len(call.Args) {
+				return types.ExprString(ast.Unparen(call.Args[i])) + "." + rest, true
+			}
+			i++
+		}
+		if len(field.Names) == 0 {
+			i++
+		}
+	}
+	return "", false
+}
+
+// isFresh reports whether e creates a new value: a composite literal, its
+// address, or new(T).
+func isFresh(info *types.Info, e ast.Expr) bool {
+	e = ast.Unparen(e)
+	if u, ok := e.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		e = ast.Unparen(u.X)
+	}
+	switch e := e.(type) {
+	case *ast.CompositeLit:
+		return true
+	case *ast.CallExpr:
+		id, ok := ast.Unparen(e.Fun).(*ast.Ident)
+		if !ok {
+			return false
+		}
+		b, ok := info.Uses[id].(*types.Builtin)
+		return ok && b.Name() == "new"
+	}
+	return false
+}
+
+func isPanic(info *types.Info, call *ast.CallExpr) bool {
+	id, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	b, ok := info.Uses[id].(*types.Builtin)
+	return ok && b.Name() == "panic"
+}
@@ -0,0 +1,451 @@
+package lockorder
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/kakkoyun/checklocks-demo/internal/annotation"
+)
+
+// heldSet maps the class key of each lock that may be held to where it was
+// acquired (token.NoPos for locks held on entry).
+type heldSet map[string]token.Pos
+
+// walker follows one function body in statement order.
+type walker struct {
+	c        *checker
+	acquires map[string]bool // Classes acquired anywhere in the body.
+}
+
+// summarize recomputes fd's summary and reports whether it grew.
+func (c *checker) summarize(fd *ast.FuncDecl) bool {
+	fn := c.pass.TypesInfo.Defs[fd.Name].(*types.Func)
+	w := &walker{c: c, acquires: make(map[string]bool)}
+	held, acquired, released := c.annotations(fd)
+	for _, k := range acquired {
+		w.acquires[k] = true
+	}
+	w.stmts(fd.Body.List, held)
+
+	f := &funcFact{
+		Acquires: slices.Sorted(maps.Keys(w.acquires)),
+		Acquired: acquired,
+		Released: released,
+	}
+	old := c.funcs[fn]
+	c.funcs[fn] = f
+	return old == nil || len(old.Acquires) != len(f.Acquires)
+}
+
+// checkFunc walks fd and every function literal in it, reporting violations.
+func (c *checker) checkFunc(fd *ast.FuncDecl) {
+	held, _, _ := c.annotations(fd)
+	w := &walker{c: c, acquires: make(map[string]bool)}
+	w.stmts(fd.Body.List, held)
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.FuncLit); ok {
+			w.stmts(lit.Body.List, heldSet{})
+		}
+		return true
+	})
+}
+
+// annotations resolves fd's checklocks annotations into the classes held on
+// entry, acquired on return and released on return.
+func (c *checker) annotations(fd *ast.FuncDecl) (held heldSet, acquired, released []string) {
+	held = heldSet{}
+	if fd.Doc == nil {
+		return held, nil, nil
+	}
+	for _, text := range annotation.List(fd.Doc) {
+		kind, path, ok := strings.Cut(text, ":")
+		if !ok {
+			continue
+		}
+		cls, ok := c.resolve(fd, path)
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "+checklocks", "+checklocksread":
+			held[cls.key()] = token.NoPos
+		case "+checklocksacquire", "+checklocksacquireread":
+			acquired = append(acquired, cls.key())
+		case "+checklocksrelease", "+checklocksreleaseread":
+			held[cls.key()] = token.NoPos
+			released = append(released, cls.key())
+		}
+	}
+	return held, acquired, released
+}
+
+// resolve maps an annotation path such as "pr.mu" or "v.pr.mu", rooted at a
+// receiver or parameter of fd, to a lock class.
+func (c *checker) resolve(fd *ast.FuncDecl, path string) (lockClass, bool) {
+	parts := strings.Split(strings.TrimSpace(path), ".")
+	if len(parts) < 2 {
+		return lockClass{}, false
+	}
+	var t types.Type
+	for _, fields := range []*ast.FieldList{fd.Recv, fd.Type.Params} {
+		if fields == nil {
+			continue
+		}
+		for _, field := range fields.List {
+			for _, name := range field.Names {
+				if name.Name == parts[0] {
+					t = c.pass.TypesInfo.TypeOf(name)
+				}
+			}
+		}
+	}
+	for _, name := range parts[1:] {
+		named := namedOf(t)
+		if named == nil {
+			return lockClass{}, false
+		}
+		st, ok := named.Underlying().(*types.Struct)
+		if !ok {
+			return lockClass{}, false
+		}
+		f := fieldByName(st, name)
+		if f == nil {
+			return lockClass{}, false
+		}
+		if annotation.IsMutex(f.Type()) {
+			return c.class(named, name)
+		}
+		t = f.Type()
+	}
+	return lockClass{}, false
+}
+
+// class returns the lock class for field of named, if named has a declared order.
+func (c *checker) class(named *types.Named, field string) (lockClass, bool) {
+	key := typeKey(named.Origin().Obj())
+	if of := c.orders[key]; of == nil {
+		return lockClass{}, false
+	}
+	return lockClass{typeKey: key, field: field}, true
+}
+
+// classOf returns the lock class of a mutex field selector such as pr.mu.
+func (c *checker) classOf(e ast.Expr) (lockClass, bool) {
+	sel, ok := ast.Unparen(e).(*ast.SelectorExpr)
+	if !ok {
+		return lockClass{}, false
+	}
+	s := c.pass.TypesInfo.Selections[sel]
+	if s == nil || s.Kind() != types.FieldVal {
+		return lockClass{}, false
+	}
+	// Walk through embedded fields to the struct that declares the mutex.
+	t := s.Recv()
+	index := s.Index()
+	for _, i := range index[:len(index)-1] {
+		named := namedOf(t)
+		if named == nil {
+			return lockClass{}, false
+		}
+		t = named.Underlying().(*types.Struct).Field(i).Type()
+	}
+	named := namedOf(t)
+	if named == nil {
+		return lockClass{}, false
+	}
+	return c.class(named, sel.Sel.Name)
+}
+
+// namedOf returns the named type of t or *t.
+func namedOf(t types.Type) *types.Named {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	n, _ := t.(*types.Named)
+	return n
+}
+
+// summary returns the lock summary of fn from this package or a fact.
+func (c *checker) summary(fn *types.Func) *funcFact {
+	if f, ok := c.funcs[fn]; ok {
+		return f
+	}
+	f := new(funcFact)
+	if !c.pass.ImportObjectFact(fn, f) {
+		f = nil
+	}
+	c.funcs[fn] = f
+	return f
+}
+
+func (w *walker) stmts(list []ast.Stmt, held heldSet) (heldSet, bool) {
+	for _, s := range list {
+		var done bool
+		held, done = w.stmt(s, held)
+		if done {
+			return held, true
+		}
+	}
+	return held, false
+}
+
+// stmt processes s and returns the locks that may be held after it, and
+// whether s never completes normally (return or panic).
+func (w *walker) stmt(s ast.Stmt, held heldSet) (heldSet, bool) {
+	switch s := s.(type) {
+	case *ast.BlockStmt:
+		return w.stmts(s.List, held)
+	case *ast.LabeledStmt:
+		return w.stmt(s.Stmt, held)
+	case *ast.ReturnStmt:
+		w.expr(s, held)
+		return held, true
+	case *ast.ExprStmt:
+		w.expr(s.X, held)
+		if call, ok := s.X.(*ast.CallExpr); ok && isPanic(w.c.pass.TypesInfo, call) {
+			return held, true
+		}
+		return held, false
+	case *ast.DeferStmt:
+		w.args(s.Call, held)
+		return held, false
+	case *ast.GoStmt:
+		w.args(s.Call, held)
+		return held, false
+	case *ast.IfStmt:
+		if s.Init != nil {
+			held, _ = w.stmt(s.Init, held)
+		}
+		w.expr(s.Cond, held)
+		b := branches{}
+		b.add(w.stmt(s.Body, clone(held)))
+		if s.Else != nil {
+			b.add(w.stmt(s.Else, clone(held)))
+		} else {
+			b.add(held, false)
+		}
+		return b.merge()
+	case *ast.ForStmt:
+		if s.Init != nil {
+			held, _ = w.stmt(s.Init, held)
+		}
+		w.expr(s.Cond, held)
+		body, _ := w.stmt(s.Body, clone(held))
+		if s.Post != nil {
+			body, _ = w.stmt(s.Post, body)
+		}
+		return union(held, body), false
+	case *ast.RangeStmt:
+		w.expr(s.X, held)
+		body, _ := w.stmt(s.Body, clone(held))
+		return union(held, body), false
+	case *ast.SwitchStmt:
+		if s.Init != nil {
+			held, _ = w.stmt(s.Init, held)
+		}
+		w.expr(s.Tag, held)
+		return w.clauses(s.Body, held)
+	case *ast.TypeSwitchStmt:
+		if s.Init != nil {
+			held, _ = w.stmt(s.Init, held)
+		}
+		held, _ = w.stmt(s.Assign, held)
+		return w.clauses(s.Body, held)
+	case *ast.SelectStmt:
+		b := branches{}
+		for _, cl := range s.Body.List {
+			cc := cl.(*ast.CommClause)
+			h := clone(held)
+			if cc.Comm != nil {
+				h, _ = w.stmt(cc.Comm, h)
+			}
+			b.add(w.stmts(cc.Body, h))
+		}
+		return b.merge()
+	default:
+		w.expr(s, held)
+		return held, false
+	}
+}
+
+// clauses handles the case clauses of a switch or type switch.
+func (w *walker) clauses(body *ast.BlockStmt, held heldSet) (heldSet, bool) {
+	b := branches{}
+	hasDefault := false
+	for _, cl := range body.List {
+		cc := cl.(*ast.CaseClause)
+		if cc.List == nil {
+			hasDefault = true
+		}
+		for _, e := range cc.List {
+			w.expr(e, held)
+		}
+		b.add(w.stmts(cc.Body, clone(held)))
+	}
+	if !hasDefault {
+		b.add(held, false)
+	}
+	return b.merge()
+}
+
+// expr processes the calls in n in evaluation order. Function literals are
+// skipped; checkFunc walks them separately.
+func (w *walker) expr(n ast.Node, held heldSet) {
+	if n == nil {
+		return
+	}
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			w.expr(n.Fun, held)
+			w.args(n, held)
+			w.call(n, held)
+			return false
+		}
+		return true
+	})
+}
+
+func (w *walker) args(call *ast.CallExpr, held heldSet) {
+	for _, a := range call.Args {
+		w.expr(a, held)
+	}
+}
+
+// call applies the effect of one call on held.
+func (w *walker) call(call *ast.CallExpr, held heldSet) {
+	fn := typeutil.StaticCallee(w.c.pass.TypesInfo, call)
+	if fn == nil {
+		return
+	}
+	fn = fn.Origin()
+	if recv := fn.Signature().Recv(); recv != nil && annotation.IsMutex(annotation.Deref(recv.Type())) {
+		sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+		if !ok {
+			return
+		}
+		cls, ok := w.c.classOf(sel.X)
+		if !ok {
+			return
+		}
+		switch fn.Name() {
+		case "Lock", "RLock":
+			w.acquire(call.Pos(), cls, held, "")
+			held[cls.key()] = call.Pos()
+		case "TryLock", "TryRLock":
+			// A failed TryLock does not block, so it cannot deadlock.
+			held[cls.key()] = call.Pos()
+		case "Unlock", "RUnlock":
+			delete(held, cls.key())
+		}
+		return
+	}
+	f := w.c.summary(fn)
+	if f == nil {
+		return
+	}
+	for _, k := range f.Acquires {
+		w.acquire(call.Pos(), parseClass(k), held, fn.Name())
+	}
+	for _, k := range f.Released {
+		delete(held, k)
+	}
+	for _, k := range f.Acquired {
+		held[k] = call.Pos()
+	}
+}
+
+// acquire records an acquisition of cls and, when reporting, flags any held
+// lock that cls is declared to precede. via names the callee for indirect
+// acquisitions.
+func (w *walker) acquire(pos token.Pos, cls lockClass, held heldSet, via string) {
+	w.acquires[cls.key()] = true
+	if !w.c.report {
+		return
+	}
+	of := w.c.orders[cls.typeKey]
+	for _, k := range slices.Sorted(maps.Keys(held)) {
+		h := parseClass(k)
+		if h.typeKey != cls.typeKey || !of.mustPrecede(cls.field, h.field) {
+			continue
+		}
+		if via != "" {
+			w.c.pass.Reportf(pos, "call to %s acquires %s while holding %s, violating +lockorder:%s",
+				via, cls.name(), h.name(), of.chainWith(cls.field, h.field))
+		} else {
+			w.c.pass.Reportf(pos, "%s acquired while holding %s, violating +lockorder:%s",
+				cls.name(), h.name(), of.chainWith(cls.field, h.field))
+		}
+	}
+}
+
+// chainWith returns the declared chain mentioning both fields, or every
+// chain if the order between them is only implied transitively.
+func (f *orderFact) chainWith(a, b string) string {
+	var all []string
+	for _, c := range f.Chains {
+		s := strings.Join(c, "<")
+		if slices.Contains(c, a) && slices.Contains(c, b) {
+			return s
+		}
+		all = append(all, s)
+	}
+	return strings.Join(all, ",")
+}
+
+// branches accumulates the outcomes of alternative paths.
+type branches struct {
+	held []heldSet
+}
+
+func (b *branches) add(h heldSet, done bool) {
+	if !done {
+		b.held = append(b.held, h)
+	}
+}
+
+// merge unions the paths that fall through; if none do, the statement
+// never completes normally.
+func (b *branches) merge() (heldSet, bool) {
+	if len(b.held) == 0 {
+		return heldSet{}, true
+	}
+	out := heldSet{}
+	for _, h := range b.held {
+		out = union(out, h)
+	}
+	return out, false
+}
+
+func union(a, b heldSet) heldSet {
+	out := clone(a)
+	for k, pos := range b {
+		if _, ok := out[k]; !ok {
+			out[k] = pos
+		}
+	}
+	return out
+}
+
+func clone(h heldSet) heldSet {
+	out := make(heldSet, len(h))
+	maps.Copy(out, h)
+	return out
+}
+
+func isPanic(info *types.Info, call *ast.CallExpr) bool {
+	id, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	b, ok := info.Uses[id].(*types.Builtin)
+	return ok && b.Name() == "panic"
+}
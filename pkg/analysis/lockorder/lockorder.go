@@ -0,0 +1,326 @@
+// Package lockorder defines an analyzer that enforces declared lock
+// hierarchies.
+//
+// A struct declares the order in which its mutexes must be acquired with a
+// +lockorder annotation in its doc comment:
+//
+//	// +lockorder:mu<rwMu<acquireReleaseMu
+//	type ProtectedResource struct {
+//		mu   sync.Mutex
+//		rwMu sync.RWMutex
+//		...
+//	}
+//
+// Any function that acquires a lock while holding one declared after it, for
+// example locking mu while rwMu is held, is reported. Locks are tracked by
+// class (type and field), not by instance, so taking b.mu while holding
+// a.rwMu is a violation even when a and b are different values: under
+// contention those two paths can deadlock against each other.
+//
+// Acquisitions are found directly (Lock, RLock, TryLock and TryRLock on an
+// annotated field) and through calls: every function exports the set of
+// classes it may acquire, and the checklocks annotations
+// +checklocksacquire, +checklocksrelease and their read variants describe
+// functions that return holding a lock or release one they were handed.
+// Preconditions stated with +checklocks and +checklocksread seed the set of
+// held locks on entry.
+//
+// The analysis is path-insensitive: a lock is considered held after a branch
+// if any branch that falls through may hold it. Deferred calls and calls in
+// function literals and go statements are not followed. A function can opt
+// out with +lockorderignore.
+package lockorder
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/kakkoyun/checklocks-demo/internal/annotation"
+)
+
+// Analyzer reports lock acquisitions that violate a +lockorder annotation.
+var Analyzer = &analysis.Analyzer{
+	Name:      "lockorder",
+	Doc:       "check that mutexes are acquired in the order declared by +lockorder annotations",
+	URL:       "https://pkg.go.dev/github.com/kakkoyun/checklocks-demo/pkg/analysis/lockorder",
+	Run:       run,
+	FactTypes: []analysis.Fact{new(orderFact), new(funcFact)},
+}
+
+// Annotation prefixes.
+const (
+	orderPrefix  = "+lockorder:"
+	ignoreMarker = "+lockorderignore"
+)
+
+// orderFact records a struct type's declared lock order: Before[a] lists the
+// fields that must not be acquired while a is held.
+type orderFact struct {
+	Chains [][]string // As written, for messages.
+	Before map[string][]string
+}
+
+func (*orderFact) AFact() {}
+
+func (f *orderFact) String() string {
+	chains := make([]string, len(f.Chains))
+	for i, c := range f.Chains {
+		chains[i] = strings.Join(c, "<")
+	}
+	return "lockorder(" + strings.Join(chains, ",") + ")"
+}
+
+// mustPrecede reports whether field a is declared to be acquired before b.
+func (f *orderFact) mustPrecede(a, b string) bool {
+	for _, x := range f.Before[b] {
+		if x == a {
+			return true
+		}
+	}
+	return false
+}
+
+// funcFact summarizes the lock classes a function acquires. Classes are
+// "pkgpath.Type.field" keys, restricted to types with a declared order.
+type funcFact struct {
+	Acquires []string // May be acquired at some point during the call.
+	Acquired []string // Held on return (+checklocksacquire).
+	Released []string // Released on return (+checklocksrelease).
+}
+
+func (*funcFact) AFact() {}
+
+func (f *funcFact) String() string {
+	var parts []string
+	for _, p := range []struct {
+		name    string
+		classes []string
+	}{{"acquires", f.Acquires}, {"acquired", f.Acquired}, {"released", f.Released}} {
+		if len(p.classes) > 0 {
+			parts = append(parts, fmt.Sprintf("%s(%s)", p.name, strings.Join(p.classes, ",")))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f *funcFact) empty() bool {
+	return len(f.Acquires) == 0 && len(f.Acquired) == 0 && len(f.Released) == 0
+}
+
+// lockClass identifies a mutex field of a struct type with a declared order.
+type lockClass struct {
+	typeKey string // "pkgpath.Type"
+	field   string
+}
+
+func (c lockClass) key() string { return c.typeKey + "." + c.field }
+
+// name is the class as shown in diagnostics, e.g. "ProtectedResource.mu".
+func (c lockClass) name() string {
+	return c.typeKey[strings.LastIndexByte(c.typeKey, '.')+1:] + "." + c.field
+}
+
+func parseClass(key string) lockClass {
+	i := strings.LastIndexByte(key, '.')
+	return lockClass{typeKey: key[:i], field: key[i+1:]}
+}
+
+func typeKey(obj *types.TypeName) string {
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+type checker struct {
+	pass   *analysis.Pass
+	orders map[string]*orderFact // By type key, local and imported.
+	funcs  map[*types.Func]*funcFact
+	decls  map[*types.Func]*ast.FuncDecl
+	report bool
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	c := &checker{
+		pass:   pass,
+		orders: make(map[string]*orderFact),
+		funcs:  make(map[*types.Func]*funcFact),
+		decls:  make(map[*types.Func]*ast.FuncDecl),
+	}
+	for _, f := range pass.AllObjectFacts() {
+		if of, ok := f.Fact.(*orderFact); ok {
+			c.orders[typeKey(f.Object.(*types.TypeName))] = of
+		}
+	}
+	c.collectOrders()
+	if len(c.orders) == 0 {
+		return nil, nil
+	}
+
+	var decls []*ast.FuncDecl
+	for _, file := range pass.Files {
+		for _, d := range file.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok || fd.Body == nil {
+				continue
+			}
+			if fn, ok := pass.TypesInfo.Defs[fd.Name].(*types.Func); ok {
+				c.decls[fn] = fd
+				decls = append(decls, fd)
+			}
+		}
+	}
+
+	// Summaries only grow, so iterate to a fixed point before reporting.
+	for changed := true; changed; {
+		changed = false
+		for _, fd := range decls {
+			if c.summarize(fd) {
+				changed = true
+			}
+		}
+	}
+	c.report = true
+	for _, fd := range decls {
+		if !annotation.Has(fd.Doc, ignoreMarker) {
+			c.checkFunc(fd)
+		}
+	}
+	// Only exported functions can be called from other packages.
+	for fn, f := range c.funcs {
+		if f != nil && !f.empty() && fn.Pkg() == pass.Pkg && fn.Exported() {
+			pass.ExportObjectFact(fn, f)
+		}
+	}
+	return nil, nil
+}
+
+// collectOrders parses +lockorder annotations on struct types in the package.
+func (c *checker) collectOrders() {
+	for _, file := range c.pass.Files {
+		for _, d := range file.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				if doc == nil {
+					continue
+				}
+				obj, ok := c.pass.TypesInfo.Defs[ts.Name].(*types.TypeName)
+				if !ok {
+					continue
+				}
+				if of := c.parseOrder(obj, doc); of != nil {
+					c.orders[typeKey(obj)] = of
+					c.pass.ExportObjectFact(obj, of)
+				}
+			}
+		}
+	}
+}
+
+func (c *checker) parseOrder(obj *types.TypeName, doc *ast.CommentGroup) *orderFact {
+	var of *orderFact
+	for _, cm := range doc.List {
+		text, _ := annotation.Parse(cm.Text)
+		spec, ok := strings.CutPrefix(text, orderPrefix)
+		if !ok {
+			continue
+		}
+		st, ok := obj.Type().Underlying().(*types.Struct)
+		if !ok {
+			c.pass.Reportf(cm.Pos(), "+lockorder on non-struct type %s", obj.Name())
+			continue
+		}
+		chain := strings.Split(spec, "<")
+		if len(chain) < 2 {
+			c.pass.Reportf(cm.Pos(), "+lockorder needs at least two fields, as in +lockorder:a<b")
+			continue
+		}
+		if bad := firstNonMutex(st, chain); bad != "" {
+			c.pass.Reportf(cm.Pos(), "+lockorder: %s is not a sync.Mutex or sync.RWMutex field of %s", bad, obj.Name())
+			continue
+		}
+		if of == nil {
+			of = &orderFact{Before: make(map[string][]string)}
+		}
+		of.Chains = append(of.Chains, chain)
+		for i := range chain {
+			for _, earlier := range chain[:i] {
+				of.addBefore(earlier, chain[i])
+			}
+		}
+	}
+	if of == nil {
+		return nil
+	}
+	of.close()
+	for _, a := range slices.Sorted(maps.Keys(of.Before)) {
+		if of.mustPrecede(a, a) {
+			c.pass.Reportf(obj.Pos(), "+lockorder annotations on %s form a cycle through %s", obj.Name(), a)
+			return nil
+		}
+	}
+	return of
+}
+
+func (of *orderFact) addBefore(a, b string) {
+	for _, x := range of.Before[b] {
+		if x == a {
+			return
+		}
+	}
+	of.Before[b] = append(of.Before[b], a)
+}
+
+// close makes Before transitive across chains and sorts it for stable facts.
+func (of *orderFact) close() {
+	for changed := true; changed; {
+		changed = false
+		for b, as := range of.Before {
+			for _, a := range as {
+				for _, aa := range of.Before[a] {
+					n := len(of.Before[b])
+					of.addBefore(aa, b)
+					changed = changed || len(of.Before[b]) != n
+				}
+			}
+		}
+	}
+	for _, as := range of.Before {
+		sort.Strings(as)
+	}
+}
+
+// firstNonMutex returns the first name that is not a mutex field of st.
+func firstNonMutex(st *types.Struct, names []string) string {
+	for _, name := range names {
+		if f := fieldByName(st, name); f == nil || !annotation.IsMutex(f.Type()) {
+			return name
+		}
+	}
+	return ""
+}
+
+func fieldByName(st *types.Struct, name string) *types.Var {
+	for i := range st.NumFields() {
+		if f := st.Field(i); f.Name() == name {
+			return f
+		}
+	}
+	return nil
+}
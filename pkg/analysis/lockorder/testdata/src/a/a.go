@@ -0,0 +1,182 @@
+package a
+
+import "sync"
+
+// +lockorder:mu<rwMu<arMu
+type R struct { // want R:`lockorder\(mu<rwMu<arMu\)`
+	mu   sync.Mutex
+	rwMu sync.RWMutex
+	arMu sync.Mutex
+	v    int
+}
+
+func (r *R) InOrder() { // want InOrder:`acquires\(a\.R\.arMu,a\.R\.mu,a\.R\.rwMu\)`
+	r.mu.Lock()
+	r.rwMu.RLock()
+	r.arMu.Lock()
+	r.arMu.Unlock()
+	r.rwMu.RUnlock()
+	r.mu.Unlock()
+}
+
+func (r *R) Inverted() { // want Inverted:`acquires\(a\.R\.mu,a\.R\.rwMu\)`
+	r.rwMu.Lock()
+	r.mu.Lock() // want `R.mu acquired while holding R.rwMu, violating \+lockorder:mu<rwMu<arMu`
+	r.mu.Unlock()
+	r.rwMu.Unlock()
+}
+
+func (r *R) ReleasedFirst() { // want ReleasedFirst:`acquires\(a\.R\.mu,a\.R\.rwMu\)`
+	r.rwMu.Lock()
+	r.rwMu.Unlock()
+	r.mu.Lock()
+	r.mu.Unlock()
+}
+
+func (r *R) Deferred() { // want Deferred:`acquires\(a\.R\.arMu,a\.R\.mu\)`
+	r.arMu.Lock()
+	defer r.arMu.Unlock()
+	r.mu.Lock() // want `R.mu acquired while holding R.arMu`
+	r.mu.Unlock()
+}
+
+// Two instances share a class, so this can deadlock against InOrder on b.
+func Instances(a, b *R) { // want Instances:`acquires\(a\.R\.mu,a\.R\.rwMu\)`
+	a.rwMu.Lock()
+	b.mu.Lock() // want `R.mu acquired while holding R.rwMu`
+	b.mu.Unlock()
+	a.rwMu.Unlock()
+}
+
+func (r *R) EarlyReturn(fail bool) { // want EarlyReturn:`acquires\(a\.R\.arMu,a\.R\.mu\)`
+	r.arMu.Lock()
+	if fail {
+		r.arMu.Unlock()
+		return
+	}
+	r.arMu.Unlock()
+	r.mu.Lock()
+	r.mu.Unlock()
+}
+
+func (r *R) Branch(b bool) { // want Branch:`acquires\(a\.R\.arMu,a\.R\.rwMu\)`
+	if b {
+		r.arMu.Lock()
+	}
+	r.rwMu.Lock() // want `R.rwMu acquired while holding R.arMu`
+	r.rwMu.Unlock()
+	if b {
+		r.arMu.Unlock()
+	}
+}
+
+func (r *R) Try() { // want Try:`acquires\(a\.R\.arMu\)`
+	r.arMu.Lock()
+	if r.mu.TryLock() { // A failed TryLock cannot block.
+		r.mu.Unlock()
+	}
+	r.arMu.Unlock()
+}
+
+// setLocked is called with mu held.
+// +checklocks:r.mu
+func (r *R) setLocked(v int) { r.v = v }
+
+// LockMu returns with mu held.
+// +checklocksacquire:r.mu
+func (r *R) LockMu() { r.mu.Lock() } // want LockMu:`acquires\(a\.R\.mu\) acquired\(a\.R\.mu\)`
+
+// UnlockMu releases mu.
+// +checklocksrelease:r.mu
+func (r *R) UnlockMu() { r.mu.Unlock() } // want UnlockMu:`released\(a\.R\.mu\)`
+
+// LockAR returns with arMu held.
+// +checklocksacquire:r.arMu
+func (r *R) LockAR() { r.arMu.Lock() } // want LockAR:`acquires\(a\.R\.arMu\) acquired\(a\.R\.arMu\)`
+
+// UnlockAR releases arMu.
+// +checklocksrelease:r.arMu
+func (r *R) UnlockAR() { r.arMu.Unlock() } // want UnlockAR:`released\(a\.R\.arMu\)`
+
+func (r *R) Set(v int) { // want Set:`acquires\(a\.R\.mu\)`
+	r.LockMu()
+	r.setLocked(v)
+	r.UnlockMu()
+}
+
+// readLocked runs with rwMu held, so taking mu through Set is inverted.
+// +checklocksread:r.rwMu
+func (r *R) readLocked() {
+	r.Set(1) // want `call to Set acquires R.mu while holding R.rwMu`
+}
+
+// Transitive acquisition through two calls.
+func (r *R) setTwice() { r.Set(1); r.Set(2) }
+
+func (r *R) Nested() { // want Nested:`acquires\(a\.R\.arMu,a\.R\.mu\)`
+	r.arMu.Lock()
+	r.setTwice() // want `call to setTwice acquires R.mu while holding R.arMu`
+	r.arMu.Unlock()
+}
+
+func (r *R) Helpers() { // want Helpers:`acquires\(a\.R\.arMu,a\.R\.mu\)`
+	r.arMu.Lock()
+	r.LockMu() // want `call to LockMu acquires R.mu while holding R.arMu`
+	r.UnlockMu()
+	r.arMu.Unlock()
+}
+
+func (r *R) Closure() { // want Closure:`acquires\(a\.R\.arMu\)`
+	r.arMu.Lock()
+	f := func() {
+		r.mu.Lock() // Runs later, without arMu.
+		r.mu.Unlock()
+	}
+	r.arMu.Unlock()
+	f()
+	func() {
+		r.rwMu.Lock()
+		r.mu.Lock() // want `R.mu acquired while holding R.rwMu`
+		r.mu.Unlock()
+		r.rwMu.Unlock()
+	}()
+}
+
+// +lockorderignore
+func (r *R) Ignored() { // want Ignored:`acquires\(a\.R\.arMu,a\.R\.mu\)`
+	r.arMu.Lock()
+	r.mu.Lock()
+	r.mu.Unlock()
+	r.arMu.Unlock()
+}
+
+// +lockorder:mu<rwMu
+type G[T any] struct { // want G:`lockorder\(mu<rwMu\)`
+	mu   sync.Mutex
+	rwMu sync.RWMutex
+	v    T
+}
+
+func (g *G[T]) Set(v T) { // want Set:`acquires\(a\.G\.mu\)`
+	g.mu.Lock()
+	g.v = v
+	g.mu.Unlock()
+}
+
+func (g *G[T]) Inverted(v T) { // want Inverted:`acquires\(a\.G\.mu,a\.G\.rwMu\)`
+	g.rwMu.Lock()
+	g.Set(v) // want `call to Set acquires G.mu while holding G.rwMu`
+	g.rwMu.Unlock()
+}
+
+// +lockorder:mu<v // want `\+lockorder: v is not a sync.Mutex or sync.RWMutex field of Bad`
+type Bad struct {
+	mu sync.Mutex
+	v  int
+}
+
+// +lockorder:a<b
+// +lockorder:b<a
+type Cycle struct { // want `\+lockorder annotations on Cycle form a cycle through a`
+	a, b sync.Mutex
+}
@@ -0,0 +1,20 @@
+package b
+
+import "a"
+
+// Facts carry both the declared order and a's function summaries.
+func Cross(r *a.R, g *a.G[int]) { // want Cross:`acquires\(a\.G\.mu,a\.R\.arMu,a\.R\.mu,a\.R\.rwMu\)`
+	r.InOrder()
+	g.Set(1)
+}
+
+func Held(r *a.R) { // want Held:`acquires\(a\.R\.arMu,a\.R\.mu\)`
+	r.LockAR()
+	r.Set(1) // want `call to Set acquires R.mu while holding R.arMu`
+	r.UnlockAR()
+}
+
+func Sequential(r *a.R) { // want Sequential:`acquires\(a\.R\.arMu,a\.R\.mu\)`
+	r.Set(1)
+	r.Try()
+}
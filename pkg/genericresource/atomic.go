@@ -0,0 +1,109 @@
+package genericresource
+
+import "sync/atomic"
+
+// Atomic holds a T that is read and replaced atomically, without a lock.
+// Each Store publishes a new copy behind an atomic.Pointer, so readers of a
+// large, immutable value, such as a configuration, never contend with each
+// other or with writers. Values must be treated as immutable once stored:
+// change a copy and store that, or use UpdateFunc.
+//
+// The zero Atomic holds the zero T. For CompareAndSwap, T must be
+// comparable; use ComparableAtomic. An Atomic must not be copied after
+// first use.
+type Atomic[T any] struct {
+	p atomic.Pointer[T]
+}
+
+// NewAtomic returns an Atomic holding v.
+func NewAtomic[T any](v T) *Atomic[T] {
+	a := new(Atomic[T])
+	a.p.Store(&v)
+	return a
+}
+
+// Load returns the current value.
+func (a *Atomic[T]) Load() T {
+	if p := a.p.Load(); p != nil {
+		return *p
+	}
+	var zero T
+	return zero
+}
+
+// Store sets the value to v.
+func (a *Atomic[T]) Store(v T) {
+	a.p.Store(&v)
+}
+
+// Swap sets the value to v and returns the previous value.
+func (a *Atomic[T]) Swap(v T) T {
+	if p := a.p.Swap(&v); p != nil {
+		return *p
+	}
+	var zero T
+	return zero
+}
+
+// UpdateFunc atomically replaces the value with fn applied to it, and
+// returns the new value. fn is given the current value and returns its
+// replacement, copying rather than modifying anything the value refers to.
+// If another store lands first, fn is called again with the newer value, so
+// it must be free of side effects.
+func (a *Atomic[T]) UpdateFunc(fn func(old T) T) T {
+	for {
+		p := a.p.Load()
+		var cur T
+		if p != nil {
+			cur = *p
+		}
+		v := fn(cur)
+		if a.p.CompareAndSwap(p, &v) {
+			return v
+		}
+	}
+}
+
+// ComparableAtomic is an Atomic of a comparable T, which adds
+// CompareAndSwap. Its representation is Atomic[T]'s, so an *Atomic[T] can be
+// converted to a *ComparableAtomic[T] to compare and swap its value, as
+// ComparableSlot does.
+type ComparableAtomic[T comparable] Atomic[T]
+
+// NewComparableAtomic returns a ComparableAtomic holding v.
+func NewComparableAtomic[T comparable](v T) *ComparableAtomic[T] {
+	return (*ComparableAtomic[T])(NewAtomic(v))
+}
+
+// Load returns the current value.
+func (a *ComparableAtomic[T]) Load() T { return (*Atomic[T])(a).Load() }
+
+// Store sets the value to v.
+func (a *ComparableAtomic[T]) Store(v T) { (*Atomic[T])(a).Store(v) }
+
+// Swap sets the value to v and returns the previous value.
+func (a *ComparableAtomic[T]) Swap(v T) T { return (*Atomic[T])(a).Swap(v) }
+
+// UpdateFunc is Atomic.UpdateFunc.
+func (a *ComparableAtomic[T]) UpdateFunc(fn func(old T) T) T {
+	return (*Atomic[T])(a).UpdateFunc(fn)
+}
+
+// CompareAndSwap sets the value to new if the current value equals old, and
+// reports whether it did.
+func (a *ComparableAtomic[T]) CompareAndSwap(old, new T) bool {
+	for {
+		p := a.p.Load()
+		var cur T
+		if p != nil {
+			cur = *p
+		}
+		if cur != old {
+			return false
+		}
+		if a.p.CompareAndSwap(p, &new) {
+			return true
+		}
+		// Another store won the race; compare against its value.
+	}
+}
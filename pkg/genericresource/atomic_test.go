@@ -0,0 +1,121 @@
+package genericresource
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAtomic(t *testing.T) {
+	var zero Atomic[string]
+	if v := zero.Load(); v != "" {
+		t.Errorf("zero Atomic Load() = %q", v)
+	}
+	if old := zero.Swap("a"); old != "" {
+		t.Errorf("Swap on a zero Atomic returned %q", old)
+	}
+
+	a := NewAtomic([]int{1})
+	if v := a.Load(); len(v) != 1 || v[0] != 1 {
+		t.Errorf("Load() = %v, want [1]", v)
+	}
+	a.Store([]int{2})
+	if old := a.Swap([]int{3}); old[0] != 2 {
+		t.Errorf("Swap returned %v, want [2]", old)
+	}
+	if v := a.UpdateFunc(func(old []int) []int { return append(old[:len(old):len(old)], 4) }); len(v) != 2 || v[1] != 4 {
+		t.Errorf("UpdateFunc returned %v, want [3 4]", v)
+	}
+}
+
+func TestAtomicCompareAndSwap(t *testing.T) {
+	a := NewComparableAtomic("a")
+	if a.CompareAndSwap("b", "c") {
+		t.Error("CompareAndSwap(b, c) swapped a value of a")
+	}
+	if !a.CompareAndSwap("a", "b") || a.Load() != "b" {
+		t.Errorf("CompareAndSwap(a, b) did not swap; value %q", a.Load())
+	}
+}
+
+func TestAtomicUpdateFuncConcurrent(t *testing.T) {
+	a := NewComparableAtomic(0)
+	const goroutines, increments = 8, 1000
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range increments {
+				a.UpdateFunc(func(old int) int { return old + 1 })
+			}
+		}()
+	}
+	wg.Wait()
+	if v := a.Load(); v != goroutines*increments {
+		t.Errorf("value = %d after concurrent updates, want %d", v, goroutines*increments)
+	}
+}
+
+func TestGenericResourceSlot(t *testing.T) {
+	gr := NewGenericResource(0, 0, 0, 0, 0, "", "")
+	if v := gr.Slot().Load(); v != 0 {
+		t.Errorf("initial Slot().Load() = %d, want 0", v)
+	}
+	gr.Slot().Store(5)
+	if v, _ := gr.GetData(); v != 0 {
+		t.Errorf("storing to the slot changed value to %d", v)
+	}
+
+	if !ComparableSlot(gr).CompareAndSwap(5, 6) || gr.Slot().Load() != 6 {
+		t.Errorf("ComparableSlot(gr).CompareAndSwap(5, 6) failed; value %d", gr.Slot().Load())
+	}
+}
+
+// config stands in for a large configuration read on every request.
+type config map[string]string
+
+func newConfig() config {
+	c := make(config)
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		c[k] = k
+	}
+	return c
+}
+
+// BenchmarkRead compares concurrent reads of a value through GetData, which
+// takes mu, with loads from the lock-free Slot. The Writer variants replace
+// the value every 100 reads.
+func BenchmarkRead(b *testing.B) {
+	for _, write := range []bool{false, true} {
+		name := ""
+		if write {
+			name = "Writer/"
+		}
+		b.Run(name+"GetData", func(b *testing.B) {
+			gr := NewGenericResource(newConfig(), nil, nil, 0, 0, "", "")
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					if write && i%100 == 0 {
+						gr.SetData(newConfig(), "")
+						continue
+					}
+					c, _ := gr.GetData()
+					_ = c["a"]
+				}
+			})
+		})
+		b.Run(name+"Slot", func(b *testing.B) {
+			gr := NewGenericResource[config](nil, nil, nil, 0, 0, "", "")
+			gr.Slot().Store(newConfig())
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					if write && i%100 == 0 {
+						gr.Slot().Store(newConfig())
+						continue
+					}
+					_ = gr.Slot().Load()["a"]
+				}
+			})
+		})
+	}
+}
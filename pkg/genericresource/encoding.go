@@ -0,0 +1,198 @@
+package genericresource
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/kakkoyun/checklocks-demo/internal/wire"
+)
+
+// binaryFormat is the leading byte of the MarshalBinary encoding. Format 2
+// added the Slot value.
+const binaryFormat = 2
+
+// resourceState is the serialized form of a GenericResource, including its
+// Slot. Fields of type T are encoded with T's own encoding.
+type resourceState[T any] struct {
+	Value               T      `json:"value"`
+	Description         string `json:"description"`
+	ID                  string `json:"id"`
+	ReadGuardedValue    T      `json:"readGuardedValue"`
+	AtomicValue         int32  `json:"atomicValue"`
+	MixedValue          int32  `json:"mixedValue"`
+	AcquireReleaseValue T      `json:"acquireReleaseValue"`
+	Slot                T      `json:"slot"`
+}
+
+// state captures an image of every field at a single point in time, under
+// mu, rwMu (read) and acquireReleaseMu, taken in the declared lock order. The
+// Slot, which takes no lock, is loaded while they are held, but may be
+// replaced concurrently like any other Slot read.
+func (gr *GenericResource[T]) state() resourceState[T] {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.rwMu.RLock()
+	defer gr.rwMu.RUnlock()
+	gr.acquireReleaseMu.Lock()
+	defer gr.acquireReleaseMu.Unlock()
+	return resourceState[T]{
+		Value:               gr.value,
+		Description:         gr.description,
+		ID:                  gr.id,
+		ReadGuardedValue:    gr.readGuardedValue,
+		AtomicValue:         atomic.LoadInt32(&gr.atomicValue),
+		MixedValue:          atomic.LoadInt32(&gr.mixedValue),
+		AcquireReleaseValue: gr.acquireReleaseValue,
+		Slot:                gr.slot.Load(),
+	}
+}
+
+// setState replaces every field with s, under the same locks as state.
+// Watchers are not notified, but WaitUntil callers are.
+func (gr *GenericResource[T]) setState(s resourceState[T]) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.rwMu.Lock()
+	defer gr.rwMu.Unlock()
+	gr.acquireReleaseMu.Lock()
+	defer gr.acquireReleaseMu.Unlock()
+	gr.value = s.Value
+	gr.description = s.Description
+	gr.notifyLocked()
+	gr.id = s.ID
+	gr.readGuardedValue = s.ReadGuardedValue
+	atomic.StoreInt32(&gr.atomicValue, s.AtomicValue)
+	atomic.StoreInt32(&gr.mixedValue, s.MixedValue)
+	gr.acquireReleaseValue = s.AcquireReleaseValue
+	gr.slot.Store(s.Slot)
+}
+
+// MarshalJSON implements json.Marshaler; T is encoded with encoding/json.
+func (gr *GenericResource[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(gr.state())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (gr *GenericResource[T]) UnmarshalJSON(data []byte) error {
+	var s resourceState[T]
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	gr.setState(s)
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder; T is encoded with encoding/gob.
+func (gr *GenericResource[T]) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(gr.state()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder.
+func (gr *GenericResource[T]) GobDecode(data []byte) error {
+	var s resourceState[T]
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	gr.setState(s)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. Values of type T use
+// T's MarshalBinary when it has one and encoding/gob otherwise.
+func (gr *GenericResource[T]) MarshalBinary() ([]byte, error) {
+	s := gr.state()
+	e := wire.Encoder{Buf: []byte{binaryFormat}}
+	for _, v := range []T{s.Value, s.ReadGuardedValue, s.AcquireReleaseValue, s.Slot} {
+		b, err := marshalT(v)
+		if err != nil {
+			return nil, err
+		}
+		e.Bytes(b)
+	}
+	e.String(s.Description)
+	e.String(s.ID)
+	e.Varint(int64(s.AtomicValue))
+	e.Varint(int64(s.MixedValue))
+	return e.Buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (gr *GenericResource[T]) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != binaryFormat {
+		return fmt.Errorf("genericresource: unsupported binary format")
+	}
+	d := wire.NewDecoder(data[1:])
+	var s resourceState[T]
+	for _, v := range []*T{&s.Value, &s.ReadGuardedValue, &s.AcquireReleaseValue, &s.Slot} {
+		b := d.Bytes()
+		if d.Err() != nil {
+			break
+		}
+		if err := unmarshalT(b, v); err != nil {
+			return err
+		}
+	}
+	s.Description = d.String()
+	s.ID = d.String()
+	s.AtomicValue = int32(d.Varint())
+	s.MixedValue = int32(d.Varint())
+	if err := d.Finish(); err != nil {
+		return fmt.Errorf("genericresource: %w", err)
+	}
+	gr.setState(s)
+	return nil
+}
+
+var (
+	binaryMarshalerType   = reflect.TypeFor[encoding.BinaryMarshaler]()
+	binaryUnmarshalerType = reflect.TypeFor[encoding.BinaryUnmarshaler]()
+)
+
+// binaryT reports whether values of type T are encoded with their own
+// MarshalBinary, which requires T to implement encoding.BinaryMarshaler and
+// *T encoding.BinaryUnmarshaler. Checking both keeps the two sides in step:
+// for T = *time.Time, T has MarshalBinary but *T has no UnmarshalBinary, so
+// both use encoding/gob.
+func binaryT[T any]() bool {
+	t := reflect.TypeFor[T]()
+	return t.Implements(binaryMarshalerType) && reflect.PointerTo(t).Implements(binaryUnmarshalerType)
+}
+
+// marshalT encodes v with T's MarshalBinary when binaryT[T] holds and
+// encoding/gob otherwise. gob cannot decode a nil pointer or interface, so
+// it is written as no bytes, which gob never produces for any other value.
+func marshalT[T any](v T) ([]byte, error) {
+	if binaryT[T]() {
+		return any(v).(encoding.BinaryMarshaler).MarshalBinary()
+	}
+	if rv := reflect.ValueOf(&v).Elem(); (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) && rv.IsNil() {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalT decodes b, written by marshalT, into v.
+func unmarshalT[T any](b []byte, v *T) error {
+	if binaryT[T]() {
+		return any(v).(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+	}
+	if len(b) == 0 {
+		var zero T
+		*v = zero
+		return nil
+	}
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
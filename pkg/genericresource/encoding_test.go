@@ -0,0 +1,106 @@
+package genericresource
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestGenericJSONRoundTrip(t *testing.T) {
+	src := NewGenericResource[string]("hello", "world", "locked", 50, 60, "desc", "id-1")
+	src.Slot().Store("slot")
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var dst GenericResource[string]
+	if err := json.Unmarshal(data, &dst); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	if got, want := dst.state(), src.state(); got != want {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestGenericGobRoundTrip(t *testing.T) {
+	src := NewGenericResource[int](1, 2, 3, 4, 5, "desc", "id-2")
+	src.Slot().Store(6)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(src); err != nil {
+		t.Fatalf("gob Encode failed: %v", err)
+	}
+	var dst GenericResource[int]
+	if err := gob.NewDecoder(&buf).Decode(&dst); err != nil {
+		t.Fatalf("gob Decode failed: %v", err)
+	}
+	if got, want := dst.state(), src.state(); got != want {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestGenericBinaryRoundTrip(t *testing.T) {
+	// netip.Addr implements encoding.BinaryMarshaler; int falls back to gob.
+	addrs := NewGenericResource(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("::1"), netip.Addr{}, 4, 5, "addrs", "id-3")
+	addrs.Slot().Store(netip.MustParseAddr("192.0.2.1"))
+	data, err := addrs.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	var dst GenericResource[netip.Addr]
+	if err := dst.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if got, want := dst.state(), addrs.state(); got != want {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", got, want)
+	}
+
+	ints := NewGenericResource[int](1, 2, 3, 4, 5, "ints", "id-4")
+	ints.Slot().Store(6)
+	data, err = ints.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	var dstInts GenericResource[int]
+	if err := dstInts.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if got, want := dstInts.state(), ints.state(); got != want {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", got, want)
+	}
+	if err := dstInts.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("UnmarshalBinary accepted truncated input")
+	}
+}
+
+func TestGenericBinaryRoundTripPointer(t *testing.T) {
+	// *time.Time has MarshalBinary but **time.Time has no UnmarshalBinary,
+	// so both sides use gob; the nil values must survive too.
+	t1 := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	src := NewGenericResource[*time.Time](&t1, nil, &t2, 4, 5, "times", "id-5")
+	data, err := src.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	var dst GenericResource[*time.Time]
+	if err := dst.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	got, want := dst.state(), src.state()
+	for _, p := range []struct {
+		name      string
+		got, want *time.Time
+	}{
+		{"value", got.Value, want.Value},
+		{"readGuardedValue", got.ReadGuardedValue, want.ReadGuardedValue},
+		{"acquireReleaseValue", got.AcquireReleaseValue, want.AcquireReleaseValue},
+		{"slot", got.Slot, want.Slot},
+	} {
+		if (p.got == nil) != (p.want == nil) || p.got != nil && !p.got.Equal(*p.want) {
+			t.Errorf("%s: got %v, want %v", p.name, p.got, p.want)
+		}
+	}
+}
@@ -5,16 +5,22 @@ import (
 	"sync/atomic"
 
 	"github.com/trailofbits/go-mutexasserts"
+
+	"github.com/kakkoyun/checklocks-demo/internal/lockassert"
+	"github.com/kakkoyun/checklocks-demo/internal/watch"
 )
 
 // GenericResource demonstrates a resource with some fields guarded by a mutex.
 // This version uses generics to see if checklocks works with generic types.
+// +lockorder:mu<rwMu<acquireReleaseMu
 type GenericResource[T any] struct {
 	mu sync.Mutex
 	// +checklocks:mu
 	value T
 	// +checklocks:mu
 	description string
+	// +checklocks:mu
+	changed chan struct{} // Closed on the next commit; see WaitUntil.
 
 	id string // This field is not guarded by mu
 
@@ -32,6 +38,10 @@ type GenericResource[T any] struct {
 	acquireReleaseMu sync.Mutex
 	// +checklocks:acquireReleaseMu
 	acquireReleaseValue T
+
+	slot Atomic[T] // Swapped atomically; not guarded by mu. See Slot.
+
+	watchers watch.Hub[Change]
 }
 
 // NewGenericResource creates a new GenericResource.
@@ -47,11 +57,26 @@ func NewGenericResource[T any](initialValue, initialReadValue, initialAcqRelValu
 	}
 }
 
+// Slot returns the resource's atomically swappable T. Unlike value, which
+// is guarded by mu, it is read and written without a lock, so readers never
+// contend with each other or with writers of the mu-guarded fields. It is
+// meant for large values that are replaced rather than modified, such as a
+// configuration. It holds the zero T until the first store.
+func (gr *GenericResource[T]) Slot() *Atomic[T] {
+	return &gr.slot
+}
+
+// ComparableSlot returns gr's Slot as a ComparableAtomic, which supports
+// CompareAndSwap. It is a function rather than a method because T must be
+// comparable.
+func ComparableSlot[T comparable](gr *GenericResource[T]) *ComparableAtomic[T] {
+	return (*ComparableAtomic[T])(&gr.slot)
+}
+
 // SetData correctly locks the mutex before writing to the guarded fields.
 func (gr *GenericResource[T]) SetData(val T, desc string) {
 	gr.mu.Lock()
-	gr.value = val
-	gr.description = desc
+	gr.setDataLocked(val, desc)
 	gr.mu.Unlock()
 }
 
@@ -68,8 +93,13 @@ func (gr *GenericResource[T]) GetData() (T, string) {
 // The +checklocks annotation enforces this assumption.
 // +checklocks:gr.mu
 func (gr *GenericResource[T]) setDataLocked(val T, desc string) {
+	lockassert.Held(&gr.mu)
+	oldVal, oldDesc := gr.value, gr.description
 	gr.value = val
 	gr.description = desc
+	gr.notifyLocked()
+	gr.publish("value", oldVal, val)
+	gr.publish("description", oldDesc, desc)
 }
 
 // SetDataWithHelper demonstrates calling an annotated function correctly (lock held).
@@ -95,6 +125,7 @@ func (gr *GenericResource[T]) GetReadGuardedValueCorrect() T {
 // readDataRLocked requires the caller to hold at least the read lock.
 // +checklocksread:gr.rwMu
 func (gr *GenericResource[T]) readDataRLocked() T {
+	lockassert.RHeld(&gr.rwMu)
 	return gr.readGuardedValue // Correct: Read lock is assumed held by annotation.
 }
 
@@ -114,7 +145,9 @@ func (gr *GenericResource[T]) ReadAtomicCorrect() int32 {
 // WriteMixedCorrect writes a mixed field atomically with the lock held (required for writes).
 func (gr *GenericResource[T]) WriteMixedCorrect(v int32) {
 	gr.mu.Lock()
+	old := gr.mixedValue
 	atomic.StoreInt32(&gr.mixedValue, v) // Correct: Lock is held and write is atomic.
+	gr.publish("mixedValue", old, v)
 	gr.mu.Unlock()
 }
 
@@ -123,13 +156,17 @@ func (gr *GenericResource[T]) WriteMixedCorrect(v int32) {
 func (gr *GenericResource[T]) AcquireAndSet(v T) {
 	// Annotation requires lock NOT be held on entry.
 	gr.acquireReleaseMu.Lock() // Acquires the lock.
+	old := gr.acquireReleaseValue
 	gr.acquireReleaseValue = v
+	gr.publish("acquireReleaseValue", old, v)
 	// Annotation implies lock IS held on exit.
+	lockassert.Held(&gr.acquireReleaseMu)
 }
 
 // GetAndRelease reads the value and releases the lock.
 // +checklocksrelease:gr.acquireReleaseMu
 func (gr *GenericResource[T]) GetAndRelease() T {
+	lockassert.Held(&gr.acquireReleaseMu)
 	// Annotation requires lock BE held on entry.
 	v := gr.acquireReleaseValue
 	gr.acquireReleaseMu.Unlock() // Releases the lock.
@@ -0,0 +1,135 @@
+package genericresource
+
+import (
+	"sync"
+
+	"github.com/kakkoyun/checklocks-demo/internal/lockassert"
+)
+
+// Map is a concurrent map guarded by a single RWMutex, following the same
+// annotation discipline as GenericResource: every access to the entries goes
+// through mu, and checklocks verifies it. Readers share the lock; writers,
+// including Update callbacks, hold it exclusively.
+//
+// The entries are never handed out: Range iterates over a copy, so callers
+// cannot reach the internal map without the lock.
+//
+// The zero Map is empty and ready for use. A Map must not be copied after
+// first use.
+type Map[K comparable, V any] struct {
+	mu sync.RWMutex
+	// +checklocks:mu
+	m map[K]V // Allocated by the first write.
+}
+
+// Load returns the value stored for k, and whether there is one.
+func (m *Map[K, V]) Load(k K) (V, bool) {
+	m.mu.RLock()
+	v, ok := m.m[k]
+	m.mu.RUnlock()
+	return v, ok
+}
+
+// Store sets the value for k.
+func (m *Map[K, V]) Store(k K, v V) {
+	m.mu.Lock()
+	m.storeLocked(k, v)
+	m.mu.Unlock()
+}
+
+// LoadOrStore returns the existing value for k if there is one, and true.
+// Otherwise it stores v and returns v and false.
+func (m *Map[K, V]) LoadOrStore(k K, v V) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.m[k]; ok {
+		return old, true
+	}
+	m.storeLocked(k, v)
+	return v, false
+}
+
+// CompareAndSwap stores new for k in m if the current value is equal to old,
+// and reports whether it did. A missing key never matches. It is a function
+// rather than a method because V must be comparable.
+func CompareAndSwap[K, V comparable](m *Map[K, V], k K, old, new V) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.m[k]
+	if !ok || cur != old {
+		return false
+	}
+	m.storeLocked(k, new)
+	return true
+}
+
+// Delete removes k.
+func (m *Map[K, V]) Delete(k K) {
+	m.mu.Lock()
+	delete(m.m, k)
+	m.mu.Unlock()
+}
+
+// Update sets k to the result of fn, called with the current value and
+// whether there is one, as one atomic read-modify-write. If fn returns false
+// the key is deleted instead. Update returns the value fn returned and
+// whether k is now present.
+//
+// fn runs with the Map's lock held, so it must not call methods of the Map.
+func (m *Map[K, V]) Update(k K, fn func(old V, ok bool) (V, bool)) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.m[k]
+	v, keep := fn(old, ok)
+	if !keep {
+		delete(m.m, k)
+		return v, false
+	}
+	m.storeLocked(k, v)
+	return v, true
+}
+
+// Len returns the number of entries.
+func (m *Map[K, V]) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.m)
+}
+
+// Range calls f for each entry, in no particular order, until f returns
+// false. It iterates over a copy taken under a single read lock, so f sees a
+// consistent view and may itself modify the Map.
+func (m *Map[K, V]) Range(f func(k K, v V) bool) {
+	for _, e := range m.entries() {
+		if !f(e.k, e.v) {
+			return
+		}
+	}
+}
+
+// entry is one key/value pair copied out of a Map.
+type entry[K comparable, V any] struct {
+	k K
+	v V
+}
+
+// entries returns a copy of the entries.
+func (m *Map[K, V]) entries() []entry[K, V] {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]entry[K, V], 0, len(m.m))
+	for k, v := range m.m {
+		out = append(out, entry[K, V]{k, v})
+	}
+	return out
+}
+
+// storeLocked sets k, allocating the map on first use.
+// +checklocks:m.mu
+func (m *Map[K, V]) storeLocked(k K, v V) {
+	lockassert.WHeld(&m.mu)
+	if m.m == nil {
+		m.m = make(map[K]V)
+	}
+	m.m[k] = v
+}
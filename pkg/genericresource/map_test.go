@@ -0,0 +1,112 @@
+package genericresource
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	var m Map[string, int]
+	if _, ok := m.Load("a"); ok || m.Len() != 0 {
+		t.Fatal("zero Map is not empty")
+	}
+	m.Delete("a") // No-op on an empty map.
+
+	m.Store("a", 1)
+	if v, ok := m.Load("a"); !ok || v != 1 {
+		t.Errorf("Load(a) = %d, %v; want 1, true", v, ok)
+	}
+	if v, loaded := m.LoadOrStore("a", 2); !loaded || v != 1 {
+		t.Errorf("LoadOrStore(a, 2) = %d, %v; want 1, true", v, loaded)
+	}
+	if v, loaded := m.LoadOrStore("b", 2); loaded || v != 2 {
+		t.Errorf("LoadOrStore(b, 2) = %d, %v; want 2, false", v, loaded)
+	}
+	if CompareAndSwap(&m, "a", 5, 6) {
+		t.Error("CompareAndSwap(a, 5, 6) swapped a value of 1")
+	}
+	if CompareAndSwap(&m, "missing", 0, 1) {
+		t.Error("CompareAndSwap on a missing key swapped")
+	}
+	if !CompareAndSwap(&m, "a", 1, 3) {
+		t.Error("CompareAndSwap(a, 1, 3) did not swap")
+	}
+	m.Delete("b")
+	if _, ok := m.Load("b"); ok || m.Len() != 1 {
+		t.Errorf("after Delete(b): Len() = %d, b present = %v", m.Len(), ok)
+	}
+	if v, _ := m.Load("a"); v != 3 {
+		t.Errorf("Load(a) = %d, want 3", v)
+	}
+}
+
+func TestMapUpdate(t *testing.T) {
+	var m Map[string, int]
+	incr := func(old int, ok bool) (int, bool) {
+		if !ok {
+			return 1, true
+		}
+		return old + 1, true
+	}
+	m.Update("n", incr)
+	if v, ok := m.Update("n", incr); !ok || v != 2 {
+		t.Errorf("Update(n, incr) = %d, %v; want 2, true", v, ok)
+	}
+	v, ok := m.Update("n", func(old int, ok bool) (int, bool) { return old, false })
+	if ok || v != 2 {
+		t.Errorf("deleting Update = %d, %v; want 2, false", v, ok)
+	}
+	if _, ok := m.Load("n"); ok {
+		t.Error("Update returning false did not delete the key")
+	}
+}
+
+func TestMapRange(t *testing.T) {
+	var m Map[int, string]
+	for i := range 5 {
+		m.Store(i, fmt.Sprint(i))
+	}
+	var keys []int
+	m.Range(func(k int, v string) bool {
+		if v != fmt.Sprint(k) {
+			t.Errorf("Range visited %d = %q", k, v)
+		}
+		keys = append(keys, k)
+		m.Delete(k) // Range must not hold the lock while calling f.
+		return true
+	})
+	sort.Ints(keys)
+	if fmt.Sprint(keys) != "[0 1 2 3 4]" || m.Len() != 0 {
+		t.Errorf("Range visited %v, Len() = %d after deleting them", keys, m.Len())
+	}
+
+	m.Store(1, "1")
+	m.Store(2, "2")
+	n := 0
+	m.Range(func(int, string) bool { n++; return false })
+	if n != 1 {
+		t.Errorf("Range called f %d times after it returned false, want 1", n)
+	}
+}
+
+func TestMapConcurrentUpdate(t *testing.T) {
+	var m Map[string, int]
+	const goroutines, increments = 8, 1000
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range increments {
+				m.Update("n", func(old int, _ bool) (int, bool) { return old + 1, true })
+				m.Range(func(string, int) bool { return true })
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := m.Load("n"); v != goroutines*increments {
+		t.Errorf("n = %d after concurrent updates, want %d", v, goroutines*increments)
+	}
+}
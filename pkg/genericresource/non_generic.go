@@ -2,6 +2,8 @@ package genericresource
 
 import (
 	"sync"
+
+	"github.com/kakkoyun/checklocks-demo/internal/lockassert"
 )
 
 // NonGenericResource is identical to GenericResource but without generics.
@@ -64,6 +66,7 @@ func (ngr *NonGenericResource) GetData() (int, string) {
 // The +checklocks annotation enforces this assumption.
 // +checklocks:ngr.mu
 func (ngr *NonGenericResource) setDataLocked(val int, desc string) {
+	lockassert.Held(&ngr.mu)
 	ngr.value = val
 	ngr.description = desc
 }
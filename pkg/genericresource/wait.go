@@ -0,0 +1,55 @@
+package genericresource
+
+import (
+	"context"
+
+	"github.com/kakkoyun/checklocks-demo/internal/lockassert"
+)
+
+// WaitUntil blocks until value and description satisfy pred, and returns
+// them. pred is called with the current data and again after every commit
+// to it by SetData, setDataLocked or decoding, until it returns true. It is
+// called without gr.mu held, so it may use gr, and it may miss states that
+// are overwritten before it runs.
+//
+// If ctx is done first, WaitUntil returns the last data pred rejected and
+// ctx.Err(). Nothing is left running after it returns.
+func (gr *GenericResource[T]) WaitUntil(ctx context.Context, pred func(val T, desc string) bool) (T, string, error) {
+	for {
+		gr.mu.Lock()
+		val, desc := gr.value, gr.description
+		changed := gr.changedLocked()
+		gr.mu.Unlock()
+		if pred(val, desc) {
+			return val, desc, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return val, desc, ctx.Err()
+		}
+	}
+}
+
+// changedLocked returns a channel that is closed by the next commit to value
+// or description.
+// +checklocks:gr.mu
+func (gr *GenericResource[T]) changedLocked() <-chan struct{} {
+	lockassert.Held(&gr.mu)
+	if gr.changed == nil {
+		gr.changed = make(chan struct{})
+	}
+	return gr.changed
+}
+
+// notifyLocked wakes every WaitUntil call waiting for a commit. The channel
+// is only allocated while someone waits, so commits without waiters cost a
+// nil check.
+// +checklocks:gr.mu
+func (gr *GenericResource[T]) notifyLocked() {
+	lockassert.Held(&gr.mu)
+	if gr.changed != nil {
+		close(gr.changed)
+		gr.changed = nil
+	}
+}
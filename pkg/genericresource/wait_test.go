@@ -0,0 +1,34 @@
+package genericresource
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitUntil(t *testing.T) {
+	gr := NewGenericResource("", "", "", 0, 0, "initial", "id")
+	done := make(chan error)
+	var got string
+	go func() {
+		var err error
+		got, _, err = gr.WaitUntil(context.Background(), func(val, _ string) bool { return val == "ready" })
+		done <- err
+	}()
+	gr.SetData("starting", "")
+	gr.SetData("ready", "")
+	if err := <-done; err != nil || got != "ready" {
+		t.Errorf("WaitUntil = %q, %v; want ready, nil", got, err)
+	}
+
+	if err := gr.UnmarshalJSON([]byte(`{"value":"decoded","description":"d"}`)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	val, desc, err := gr.WaitUntil(ctx, func(val, _ string) bool { return val == "never" })
+	if !errors.Is(err, context.DeadlineExceeded) || val != "decoded" || desc != "d" {
+		t.Errorf("canceled WaitUntil = %q, %q, %v; want the last data and DeadlineExceeded", val, desc, err)
+	}
+}
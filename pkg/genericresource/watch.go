@@ -0,0 +1,57 @@
+package genericresource
+
+import (
+	"context"
+
+	"github.com/kakkoyun/checklocks-demo/internal/watch"
+)
+
+// Change describes a committed write to one field of a GenericResource.
+// Version increases by one for every change to the resource, so a gap in
+// the versions a watcher receives means events were dropped.
+type Change struct {
+	Field   string
+	Old     any
+	New     any
+	Version uint64
+}
+
+// WatchOption configures a single Watch subscription.
+type WatchOption = watch.Option
+
+// SlowConsumerPolicy decides what happens when a watcher's buffer is full.
+type SlowConsumerPolicy = watch.Policy
+
+const (
+	// DropOldest discards the oldest buffered change; writers never block.
+	// This is the default.
+	DropOldest = watch.DropOldest
+	// Block makes writers wait, while holding the resource's lock, until the
+	// watcher has room or its context is done.
+	Block = watch.Block
+)
+
+// WithWatchBuffer sets the watcher's channel capacity (default 16).
+func WithWatchBuffer(n int) WatchOption {
+	return watch.WithBuffer(n)
+}
+
+// WithWatchPolicy sets the watcher's slow-consumer policy (default DropOldest).
+func WithWatchPolicy(p SlowConsumerPolicy) WatchOption {
+	return watch.WithPolicy(p)
+}
+
+// Watch returns a channel that receives a Change every time SetData,
+// setDataLocked, AcquireAndSet or WriteMixedCorrect commits a write.
+// The channel is closed once ctx is done.
+func (gr *GenericResource[T]) Watch(ctx context.Context, opts ...WatchOption) <-chan Change {
+	return gr.watchers.Subscribe(ctx, opts...)
+}
+
+// publish notifies watchers of a write. Callers hold the lock guarding field,
+// so changes to the same field are published in commit order.
+func (gr *GenericResource[T]) publish(field string, old, new any) {
+	gr.watchers.Publish(func(version uint64) Change {
+		return Change{Field: field, Old: old, New: new, Version: version}
+	})
+}
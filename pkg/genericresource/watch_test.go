@@ -0,0 +1,29 @@
+package genericresource
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGenericWatchReceivesChanges(t *testing.T) {
+	gr := NewGenericResource[string]("hello", "world", "locked", 50, 60, "desc", "id-1")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := gr.Watch(ctx)
+
+	gr.SetDataWithHelper("updated", "new desc")
+	gr.WriteMixedCorrect(61)
+	_ = gr.CallAcquireReleaseCorrect()
+
+	want := []Change{
+		{Field: "value", Old: "hello", New: "updated", Version: 1},
+		{Field: "description", Old: "desc", New: "new desc", Version: 2},
+		{Field: "mixedValue", Old: int32(60), New: int32(61), Version: 3},
+		{Field: "acquireReleaseValue", Old: "locked", New: "", Version: 4},
+	}
+	for _, w := range want {
+		if got := <-ch; got != w {
+			t.Errorf("expected %+v, got %+v", w, got)
+		}
+	}
+}
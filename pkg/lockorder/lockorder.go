@@ -0,0 +1,232 @@
+// Package lockorder is a dynamic lock-order checker in the spirit of the
+// Linux kernel's lockdep. Locks are grouped into classes (for example
+// "ProtectedResource.mu"); every time a goroutine acquires a class while
+// holding another, the order is recorded in a global graph. The first time
+// an acquisition would close a cycle in that graph, the Detector reports an
+// Inversion carrying the stack trace of every edge in the cycle, even if
+// the program did not actually deadlock.
+//
+// Detection is opt-in and costs a stack walk per acquisition, so it is
+// meant for tests, not production.
+package lockorder
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Edge records that To was acquired while From was held, along with the
+// stack of the first acquisition observed in that order.
+type Edge struct {
+	From  string
+	To    string
+	Stack string
+}
+
+// Inversion is a cycle in the lock-order graph. The last edge is the
+// acquisition that closed the cycle; the others were observed earlier.
+type Inversion struct {
+	Cycle []Edge
+}
+
+// String renders the cycle and every edge's stack trace.
+func (inv *Inversion) String() string {
+	var b strings.Builder
+	b.WriteString("lockorder: potential deadlock: ")
+	for _, e := range inv.Cycle {
+		b.WriteString(e.From + " -> ")
+	}
+	b.WriteString(inv.Cycle[0].From)
+	for _, e := range inv.Cycle {
+		fmt.Fprintf(&b, "\n\n--- %s acquired while holding %s:\n%s", e.To, e.From, e.Stack)
+	}
+	return b.String()
+}
+
+// Detector tracks held locks per goroutine and the global order graph.
+// The zero value is not usable; create one with New. Acquire and Release
+// are safe to call on a nil receiver, which records nothing.
+type Detector struct {
+	report func(*Inversion)
+
+	mu sync.Mutex
+	// held maps a goroutine ID to the classes it holds, in acquisition order.
+	// +checklocks:mu
+	held map[uint64][]string
+	// +checklocks:mu
+	edges map[string]map[string]*Edge
+	// +checklocks:mu
+	reported map[string]bool
+	// +checklocks:mu
+	inversions []*Inversion
+}
+
+// New returns a Detector that calls report, if non-nil, for each new
+// inversion. report runs on the acquiring goroutine before it blocks on the
+// lock, so it fires even when the inversion turns into a real deadlock.
+func New(report func(*Inversion)) *Detector {
+	return &Detector{
+		report:   report,
+		held:     make(map[uint64][]string),
+		edges:    make(map[string]map[string]*Edge),
+		reported: make(map[string]bool),
+	}
+}
+
+// Acquire records that the calling goroutine is about to acquire class.
+// Call it immediately before Lock or RLock. Re-acquiring a class already
+// held (for example a second instance of the same struct) adds no edge.
+func (d *Detector) Acquire(class string) {
+	if d == nil {
+		return
+	}
+	gid := goid()
+	var found []*Inversion
+
+	d.mu.Lock()
+	held := d.held[gid]
+	for _, h := range held {
+		if h == class || d.edges[h][class] != nil {
+			continue
+		}
+		e := &Edge{From: h, To: class, Stack: stack()}
+		// If class already reaches h, adding h -> class closes a cycle.
+		if path := d.pathLocked(class, h); path != nil {
+			cycle := append(path, *e)
+			if key := cycleKey(cycle); !d.reported[key] {
+				d.reported[key] = true
+				inv := &Inversion{Cycle: cycle}
+				d.inversions = append(d.inversions, inv)
+				found = append(found, inv)
+			}
+		}
+		if d.edges[h] == nil {
+			d.edges[h] = make(map[string]*Edge)
+		}
+		d.edges[h][class] = e
+	}
+	d.held[gid] = append(held, class)
+	d.mu.Unlock()
+
+	if d.report != nil {
+		for _, inv := range found {
+			d.report(inv)
+		}
+	}
+}
+
+// Release records that the calling goroutine released class. Call it
+// immediately before Unlock or RUnlock.
+func (d *Detector) Release(class string) {
+	if d == nil {
+		return
+	}
+	d.ReleaseFor(goid(), class)
+}
+
+// Owner returns the calling goroutine's ID, under which Acquire records its
+// holds, for a later ReleaseFor. It returns 0 on a nil receiver.
+func (d *Detector) Owner() uint64 {
+	if d == nil {
+		return 0
+	}
+	return goid()
+}
+
+// ReleaseFor records that class, acquired by the goroutine owner (see
+// Owner), was released, possibly by another goroutine: a lock held by a
+// handle, such as a lease reclaimed by a timer, is not always released by
+// the goroutine that acquired it.
+func (d *Detector) ReleaseFor(owner uint64, class string) {
+	if d == nil {
+		return
+	}
+	gid := owner
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	held := d.held[gid]
+	for i := len(held) - 1; i >= 0; i-- {
+		if held[i] == class {
+			held = append(held[:i], held[i+1:]...)
+			break
+		}
+	}
+	if len(held) == 0 {
+		delete(d.held, gid)
+	} else {
+		d.held[gid] = held
+	}
+}
+
+// Inversions returns every inversion found so far.
+func (d *Detector) Inversions() []*Inversion {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*Inversion(nil), d.inversions...)
+}
+
+// pathLocked returns the edges of a path from -> ... -> to, or nil.
+// +checklocks:d.mu
+func (d *Detector) pathLocked(from, to string) []Edge {
+	prev := map[string]*Edge{from: nil}
+	queue := []string{from}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n == to {
+			var path []Edge
+			for e := prev[n]; e != nil; e = prev[e.From] {
+				path = append([]Edge{*e}, path...)
+			}
+			return path
+		}
+		for next, e := range d.edges[n] {
+			if _, seen := prev[next]; !seen {
+				prev[next] = e
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// cycleKey identifies a cycle independent of where it was entered.
+func cycleKey(cycle []Edge) string {
+	names := make([]string, len(cycle))
+	start := 0
+	for i, e := range cycle {
+		names[i] = e.From
+		if e.From < cycle[start].From {
+			start = i
+		}
+	}
+	return strings.Join(append(names[start:], names[:start]...), "->")
+}
+
+func stack() string {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// goid returns the calling goroutine's ID, parsed from its stack header
+// ("goroutine 123 [running]:").
+func goid() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
@@ -0,0 +1,116 @@
+package lockorder
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// inOrder acquires and releases classes nested in the given order.
+func inOrder(d *Detector, classes ...string) {
+	for _, c := range classes {
+		d.Acquire(c)
+	}
+	for i := len(classes) - 1; i >= 0; i-- {
+		d.Release(classes[i])
+	}
+}
+
+func TestConsistentOrderIsQuiet(t *testing.T) {
+	d := New(nil)
+	for range 3 {
+		inOrder(d, "a", "b", "c")
+		inOrder(d, "a", "c")
+	}
+	if inv := d.Inversions(); len(inv) != 0 {
+		t.Errorf("expected no inversions, got %v", inv)
+	}
+}
+
+func TestInversionReportedWithBothStacks(t *testing.T) {
+	var reports []*Inversion
+	d := New(func(inv *Inversion) { reports = append(reports, inv) })
+
+	inOrder(d, "a", "b")
+	// The opposite order on another goroutine; nothing actually deadlocks
+	// because the two never overlap.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		inOrder(d, "b", "a")
+	}()
+	wg.Wait()
+
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+	inv := reports[0]
+	if len(inv.Cycle) != 2 || inv.Cycle[0].From != "a" || inv.Cycle[1].From != "b" {
+		t.Fatalf("unexpected cycle: %+v", inv.Cycle)
+	}
+	for _, e := range inv.Cycle {
+		if !strings.Contains(e.Stack, "inOrder") {
+			t.Errorf("edge %s -> %s has no useful stack: %q", e.From, e.To, e.Stack)
+		}
+	}
+	if s := inv.String(); !strings.Contains(s, "a -> b -> a") {
+		t.Errorf("String missing cycle: %s", s)
+	}
+
+	// The same inversion is reported only once.
+	inOrder(d, "b", "a")
+	if len(reports) != 1 {
+		t.Errorf("inversion reported again: %d reports", len(reports))
+	}
+}
+
+func TestLongerCycle(t *testing.T) {
+	d := New(nil)
+	inOrder(d, "a", "b")
+	inOrder(d, "b", "c")
+	inOrder(d, "c", "a")
+	inv := d.Inversions()
+	if len(inv) != 1 || len(inv[0].Cycle) != 3 {
+		t.Fatalf("expected one 3-edge cycle, got %v", inv)
+	}
+}
+
+func TestSameClassNesting(t *testing.T) {
+	d := New(nil)
+	inOrder(d, "a", "a")
+	if inv := d.Inversions(); len(inv) != 0 {
+		t.Errorf("same-class nesting reported: %v", inv)
+	}
+}
+
+func TestReleaseFor(t *testing.T) {
+	d := New(nil)
+	d.Acquire("b")
+	owner := d.Owner()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.ReleaseFor(owner, "b") // Released elsewhere, as by a timer.
+	}()
+	<-done
+	inOrder(d, "a", "b")
+	if inv := d.Inversions(); len(inv) != 0 {
+		t.Errorf("a hold released by another goroutine was still tracked: %v", inv)
+	}
+	if (*Detector)(nil).Owner() != 0 {
+		t.Error("Owner on a nil Detector is not 0")
+	}
+}
+
+func TestGoid(t *testing.T) {
+	main := goid()
+	if main == 0 {
+		t.Fatal("goid returned 0")
+	}
+	ch := make(chan uint64)
+	go func() { ch <- goid() }()
+	if other := <-ch; other == main {
+		t.Errorf("two goroutines share ID %d", main)
+	}
+}
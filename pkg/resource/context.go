@@ -0,0 +1,100 @@
+package resource
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Backoff bounds for lockCtx's TryLock polling loop.
+const (
+	minLockBackoff = time.Microsecond
+	maxLockBackoff = time.Millisecond
+)
+
+// lockCtx acquires mu, giving up with ctx.Err() once ctx is done.
+// sync.Mutex has no context-aware Lock, so this polls TryLock with an
+// exponential backoff. Keeping mu a plain sync.Mutex means the fields it
+// guards keep their +checklocks annotations unchanged, at two costs:
+//
+//   - Once the first TryLock fails, each retry can lag the Unlock it was
+//     waiting for by up to maxLockBackoff.
+//   - TryLock always fails while the mutex is in starvation mode, where
+//     Unlock hands it directly to a goroutine blocked in Lock. A ctx caller
+//     racing steady Lock callers can therefore time out even though the
+//     lock keeps changing hands.
+//
+// Metrics and lock order are recorded under id, as in the lock helpers.
+// On a nil return the caller owns mu and must unlock it.
+func (pr *ProtectedResource) lockCtx(ctx context.Context, id lockID, mu *sync.Mutex) error {
+	d := pr.lockOrder.Load()
+	d.Acquire(lockClasses[id])
+	s := pr.metrics.Load().get(id)
+	start := s.now()
+	if mu.TryLock() {
+		s.acquired(start, true)
+		return nil
+	}
+	backoff := minLockBackoff
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			d.Release(lockClasses[id])
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if mu.TryLock() {
+			s.acquired(start, true)
+			return nil
+		}
+		backoff = min(backoff*2, maxLockBackoff)
+		timer.Reset(backoff)
+	}
+}
+
+// --- Context-aware Locking ---
+
+// SetDataCtx is like SetData but gives up with ctx.Err() if pr.mu cannot be
+// acquired before ctx is done. The wait polls rather than queues: it can lag
+// an unlock by up to a millisecond, and under heavy contention from SetData
+// and other blocking callers it may keep losing until ctx expires.
+func (pr *ProtectedResource) SetDataCtx(ctx context.Context, val int, desc string) error {
+	if err := pr.lockCtx(ctx, lockMu, &pr.mu); err != nil {
+		return err
+	}
+	// lockCtx succeeded, so pr.mu is held; tell the analyzer.
+	pr.setDataLocked(val, desc) // +checklocksforce:pr.mu
+	pr.unlockMu()
+	return nil
+}
+
+// GetDataCtx is like GetData but gives up with ctx.Err() if pr.mu cannot be
+// acquired before ctx is done. It waits the way SetDataCtx does.
+func (pr *ProtectedResource) GetDataCtx(ctx context.Context) (int, string, error) {
+	if err := pr.lockCtx(ctx, lockMu, &pr.mu); err != nil {
+		return 0, "", err
+	}
+	v := pr.value // +checklocksforce:pr.mu
+	d := pr.description
+	pr.unlockMu()
+	return v, d, nil
+}
+
+// AcquireAndSetCtx is like AcquireAndSet but gives up with ctx.Err() if
+// pr.acquireReleaseMu cannot be acquired before ctx is done, waiting the way
+// SetDataCtx does. On a nil error the lock is held and the caller must
+// release it with GetAndRelease. The analyzer cannot express a conditional
+// acquire, so this function is ignored and callers should use
+// +checklocksforce:pr.acquireReleaseMu after checking the error.
+// +checklocksignore
+func (pr *ProtectedResource) AcquireAndSetCtx(ctx context.Context, v int) error {
+	if err := pr.lockCtx(ctx, lockAcquireReleaseMu, &pr.acquireReleaseMu); err != nil {
+		return err
+	}
+	old := pr.acquireReleaseValue
+	pr.acquireReleaseValue = v
+	pr.publish("acquireReleaseValue", old, v)
+	return nil
+}
@@ -0,0 +1,80 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSetDataCtxCorrect(t *testing.T) {
+	pr := newTestResource()
+	ctx := context.Background()
+	if err := pr.SetDataCtx(ctx, 7, "ctx update"); err != nil {
+		t.Fatalf("SetDataCtx failed: %v", err)
+	}
+	val, desc, err := pr.GetDataCtx(ctx)
+	if err != nil {
+		t.Fatalf("GetDataCtx failed: %v", err)
+	}
+	if val != 7 || desc != "ctx update" {
+		t.Errorf("SetDataCtx/GetDataCtx failed: expected 7/ctx update, got %d/%s", val, desc)
+	}
+}
+
+func TestSetDataCtxDeadline(t *testing.T) {
+	pr := newTestResource()
+	pr.mu.Lock() // Simulate a stuck holder.
+	defer pr.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pr.SetDataCtx(ctx, 8, "never"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SetDataCtx: expected DeadlineExceeded, got %v", err)
+	}
+	if _, _, err := pr.GetDataCtx(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetDataCtx: expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestSetDataCtxWaitsForRelease(t *testing.T) {
+	pr := newTestResource()
+	locked := make(chan struct{})
+	go func() {
+		pr.mu.Lock()
+		close(locked)
+		time.Sleep(5 * time.Millisecond)
+		pr.mu.Unlock()
+	}()
+	<-locked
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pr.SetDataCtx(ctx, 9, "after release"); err != nil {
+		t.Fatalf("SetDataCtx failed: %v", err)
+	}
+	if val, _ := pr.GetData(); val != 9 {
+		t.Errorf("SetDataCtx failed: expected 9, got %d", val)
+	}
+}
+
+func TestAcquireAndSetCtx(t *testing.T) {
+	pr := newTestResource()
+	ctx := context.Background()
+	if err := pr.AcquireAndSetCtx(ctx, 11); err != nil {
+		t.Fatalf("AcquireAndSetCtx failed: %v", err)
+	}
+
+	// The lock is still held, so a second acquire must time out.
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := pr.AcquireAndSetCtx(tctx, 12); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second AcquireAndSetCtx: expected DeadlineExceeded, got %v", err)
+	}
+
+	// AcquireAndSetCtx holds the lock only on success, which checklocks
+	// cannot tell.
+	if v := pr.GetAndRelease(); v != 11 { // +checklocksforce:pr.acquireReleaseMu
+		t.Errorf("GetAndRelease failed: expected 11, got %d", v)
+	}
+}
@@ -0,0 +1,175 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/kakkoyun/checklocks-demo/internal/lockassert"
+	"github.com/kakkoyun/checklocks-demo/internal/wire"
+)
+
+// binaryFormat is the leading byte of the MarshalBinary encoding.
+const binaryFormat = 1
+
+// resourceState is the serialized form of a ProtectedResource.
+type resourceState struct {
+	Value               int    `json:"value"`
+	Description         string `json:"description"`
+	Version             uint64 `json:"version"`
+	ID                  string `json:"id"`
+	ReadGuardedValue    int    `json:"readGuardedValue"`
+	AtomicValue         int32  `json:"atomicValue"`
+	MixedValue          int32  `json:"mixedValue"`
+	AcquireReleaseValue int    `json:"acquireReleaseValue"`
+}
+
+// state captures an image of every field at a single point in time, under
+// mu, rwMu (read) and acquireReleaseMu, taken in the declared lock order. It
+// therefore waits, holding mu, for a caller holding acquireReleaseMu between
+// AcquireAndSet and GetAndRelease or through a Lease.
+func (pr *ProtectedResource) state() resourceState {
+	pr.lockMu()
+	defer pr.unlockMu()
+	held := pr.rlockRWMu()
+	defer pr.runlockRWMu(held)
+	pr.lockAcquireReleaseMu()
+	defer pr.unlockAcquireReleaseMu()
+	s := pr.stateLocked()
+	s.AcquireReleaseValue = pr.acquireReleaseValue
+	return s
+}
+
+// stateLocked is state, without acquireReleaseValue, for callers that
+// already hold mu and rwMu.
+// +checklocks:pr.mu
+// +checklocksread:pr.rwMu
+func (pr *ProtectedResource) stateLocked() resourceState {
+	lockassert.Held(&pr.mu)
+	lockassert.RHeld(&pr.rwMu)
+	return resourceState{
+		Value:            pr.value,
+		Description:      pr.description,
+		Version:          pr.version,
+		ID:               pr.id,
+		ReadGuardedValue: pr.readGuardedValue,
+		AtomicValue:      atomic.LoadInt32(&pr.atomicValue),
+		MixedValue:       atomic.LoadInt32(&pr.mixedValue),
+	}
+}
+
+// setState replaces every field with s, under the same locks as state. A
+// durable resource logs the whole state as one record first and, if that
+// fails, returns the log's error and leaves every field unchanged. Watchers
+// are not notified, but WaitUntil callers are; the version is restored from
+// s.
+func (pr *ProtectedResource) setState(s resourceState) error {
+	pr.lockMu()
+	defer pr.unlockMu()
+	pr.lockRWMu()
+	defer pr.unlockRWMu()
+	pr.lockAcquireReleaseMu()
+	defer pr.unlockAcquireReleaseMu()
+	if !pr.wal.logSetState(s, func() { pr.applyState(s) }) {
+		return pr.Err()
+	}
+	return nil
+}
+
+// applyState is setState's apply function. checklocks does not carry the
+// locks setState holds into the closure that calls it, so the body is not
+// checked; it asserts them at run time instead.
+// +checklocksignore
+func (pr *ProtectedResource) applyState(s resourceState) {
+	lockassert.Held(&pr.mu)
+	lockassert.WHeld(&pr.rwMu)
+	lockassert.Held(&pr.acquireReleaseMu)
+	pr.value = s.Value
+	pr.description = s.Description
+	atomic.StoreUint64(&pr.version, s.Version)
+	pr.notifyLocked()
+	pr.id = s.ID
+	pr.readGuardedValue = s.ReadGuardedValue
+	atomic.StoreInt32(&pr.atomicValue, s.AtomicValue)
+	atomic.StoreInt32(&pr.mixedValue, s.MixedValue)
+	pr.acquireReleaseValue = s.AcquireReleaseValue
+}
+
+// MarshalJSON implements json.Marshaler.
+func (pr *ProtectedResource) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pr.state())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (pr *ProtectedResource) UnmarshalJSON(data []byte) error {
+	var s resourceState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return pr.setState(s)
+}
+
+// GobEncode implements gob.GobEncoder.
+func (pr *ProtectedResource) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(pr.state()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder.
+func (pr *ProtectedResource) GobDecode(data []byte) error {
+	var s resourceState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	return pr.setState(s)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler with a compact varint
+// encoding prefixed by a format byte.
+func (pr *ProtectedResource) MarshalBinary() ([]byte, error) {
+	e := wire.Encoder{Buf: []byte{binaryFormat}}
+	encodeState(&e, pr.state())
+	return e.Buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (pr *ProtectedResource) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != binaryFormat {
+		return fmt.Errorf("resource: unsupported binary format")
+	}
+	d := wire.NewDecoder(data[1:])
+	s := decodeState(d)
+	if err := d.Finish(); err != nil {
+		return fmt.Errorf("resource: %w", err)
+	}
+	return pr.setState(s)
+}
+
+func encodeState(e *wire.Encoder, s resourceState) {
+	e.Varint(int64(s.Value))
+	e.String(s.Description)
+	e.Uvarint(s.Version)
+	e.String(s.ID)
+	e.Varint(int64(s.ReadGuardedValue))
+	e.Varint(int64(s.AtomicValue))
+	e.Varint(int64(s.MixedValue))
+	e.Varint(int64(s.AcquireReleaseValue))
+}
+
+func decodeState(d *wire.Decoder) resourceState {
+	return resourceState{
+		Value:               int(d.Varint()),
+		Description:         d.String(),
+		Version:             d.Uvarint(),
+		ID:                  d.String(),
+		ReadGuardedValue:    int(d.Varint()),
+		AtomicValue:         int32(d.Varint()),
+		MixedValue:          int32(d.Varint()),
+		AcquireReleaseValue: int(d.Varint()),
+	}
+}
@@ -0,0 +1,139 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// populatedResource returns a resource with every field moved off its initial value.
+func populatedResource() *ProtectedResource {
+	pr := newTestResource()
+	pr.SetData(7, "encoded")
+	pr.SetID("id-enc")
+	pr.IncrementAtomicCorrect()
+	pr.WriteMixedCorrect(31)
+	pr.AcquireAndSet(41)
+	_ = pr.GetAndRelease()
+	return pr
+}
+
+func checkRoundTrip(t *testing.T, got *ProtectedResource) {
+	t.Helper()
+	want := populatedResource().state()
+	if s := got.state(); s != want {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", s, want)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	data, err := json.Marshal(populatedResource())
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	if !bytes.Contains(data, []byte(`"description":"encoded"`)) {
+		t.Errorf("unexpected JSON: %s", data)
+	}
+	var pr ProtectedResource
+	if err := json.Unmarshal(data, &pr); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	checkRoundTrip(t, &pr)
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(populatedResource()); err != nil {
+		t.Fatalf("gob Encode failed: %v", err)
+	}
+	var pr ProtectedResource
+	if err := gob.NewDecoder(&buf).Decode(&pr); err != nil {
+		t.Fatalf("gob Decode failed: %v", err)
+	}
+	checkRoundTrip(t, &pr)
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	data, err := populatedResource().MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	var pr ProtectedResource
+	if err := pr.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	checkRoundTrip(t, &pr)
+
+	if err := pr.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("UnmarshalBinary accepted truncated input")
+	}
+	if err := pr.UnmarshalBinary(append([]byte{99}, data[1:]...)); err == nil {
+		t.Error("UnmarshalBinary accepted an unknown format byte")
+	}
+}
+
+// TestMarshalConsistent checks that value and description are always
+// captured from the same commit while writers run concurrently.
+func TestMarshalConsistent(t *testing.T) {
+	pr := newTestResource()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			pr.SetData(i, string(rune('a'+i%26)))
+		}
+	}()
+	for range 200 {
+		data, err := json.Marshal(pr)
+		if err != nil {
+			t.Fatalf("json.Marshal failed: %v", err)
+		}
+		var s resourceState
+		if err := json.Unmarshal(data, &s); err != nil {
+			t.Fatalf("json.Unmarshal failed: %v", err)
+		}
+		if s.Version > 0 && s.Description != string(rune('a'+s.Value%26)) {
+			t.Fatalf("torn read: value %d with description %q", s.Value, s.Description)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestMarshalWaitsForAcquire(t *testing.T) {
+	pr := newTestResource()
+	pr.SetData(1, "before acquire")
+	pr.AcquireAndSet(41)
+	marshaled := make(chan []byte)
+	go func() {
+		data, _ := pr.MarshalJSON()
+		marshaled <- data
+	}()
+
+	// The image is taken under acquireReleaseMu, so it cannot complete
+	// until the holder releases it.
+	select {
+	case <-marshaled:
+		t.Fatal("MarshalJSON completed while acquireReleaseMu was held")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	_ = pr.GetAndRelease()
+	var s resourceState
+	if err := json.Unmarshal(<-marshaled, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.AcquireReleaseValue != 41 || s.Value != 1 {
+		t.Errorf("marshaled value/acquireReleaseValue %d/%d, want 1/41", s.Value, s.AcquireReleaseValue)
+	}
+}
@@ -0,0 +1,240 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kakkoyun/checklocks-demo/internal/lockassert"
+	"github.com/kakkoyun/checklocks-demo/pkg/lockorder"
+)
+
+// --- Leases ---
+
+var (
+	// ErrLeaseReleased is returned by the methods of a released Lease.
+	ErrLeaseReleased = errors.New("resource: lease released")
+	// ErrLeaseExpired is returned by the methods of a Lease whose timeout
+	// reclaimed the lock.
+	ErrLeaseExpired = errors.New("resource: lease expired")
+
+	// errLeaseLeaked ends a Lease that became unreachable unreleased. No
+	// caller can observe it.
+	errLeaseLeaked = errors.New("resource: lease leaked")
+)
+
+// LeaseOption configures a Lease.
+type LeaseOption func(*leaseOptions)
+
+type leaseOptions struct {
+	timeout time.Duration
+	onLeak  func(stack string)
+}
+
+// WithLeaseTimeout makes the lease release acquireReleaseMu by itself once d
+// has passed, after which its methods return ErrLeaseExpired. Use it when a
+// stuck holder must not block everyone else forever.
+func WithLeaseTimeout(d time.Duration) LeaseOption {
+	return func(o *leaseOptions) { o.timeout = d }
+}
+
+// WithLeakReport sets the function called, with the stack of the goroutine
+// that acquired it, for a Lease garbage collected without being released.
+// The default writes the stack to standard error. The function runs on the
+// runtime's cleanup goroutine and must not block.
+func WithLeakReport(report func(stack string)) LeaseOption {
+	return func(o *leaseOptions) { o.onLeak = report }
+}
+
+// Lease holds pr.acquireReleaseMu on behalf of its owner, replacing the
+// AcquireAndSet/GetAndRelease pair: Acquire takes the lock, Value and Set use
+// acquireReleaseValue under it, and pr.ReleaseLease gives it back.
+// checklocks pairs the two, as it pairs AcquireAndSet with GetAndRelease, so
+// a path that returns without calling ReleaseLease is reported. Release is
+// the same for code that has the Lease but not pr; both are idempotent.
+//
+// Forgetting to release is detected: a Lease that is garbage collected
+// while it still holds the lock is reported, with the stack of its
+// acquisition, and the lock is released. WithLeaseTimeout bounds how long a
+// lease may hold the lock even while it is still referenced.
+//
+// A Lease is safe for concurrent use. With TrackLockOrder, the lock counts
+// as held by the goroutine that called Acquire until the lease ends,
+// whichever goroutine ends it.
+type Lease struct {
+	s *leaseState
+}
+
+// leaseState is the part of a Lease its timer and leak cleanup use. They
+// must not refer to the Lease itself, or it would never become unreachable.
+type leaseState struct {
+	// Set by Acquire and never changed; not guarded by mu.
+	pr     *ProtectedResource
+	pcs    []uintptr          // Acquire's call stack, formatted on a leak; not guarded by mu.
+	onLeak func(stack string) // Not guarded by mu.
+	// The detector the lock was reported to, and the goroutine it was
+	// reported for.
+	lockOrder *lockorder.Detector // Not guarded by mu.
+	owner     uint64              // Not guarded by mu.
+
+	mu sync.Mutex
+	// end is why the lease ended, or nil while it holds
+	// pr.acquireReleaseMu.
+	// +checklocks:mu
+	end error
+	// +checklocks:mu
+	timer *time.Timer // Set with WithLeaseTimeout.
+}
+
+// Acquire blocks until pr.acquireReleaseMu is free and returns a Lease
+// holding it. The caller must release it with pr.ReleaseLease.
+//
+// A timer or the garbage collector may also release the lock, which the
+// analyzer cannot follow; Lease methods assert at runtime that it is held.
+// +checklocksacquire:pr.acquireReleaseMu
+func (pr *ProtectedResource) Acquire(opts ...LeaseOption) *Lease {
+	o := leaseOptions{onLeak: reportLeakedLease}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var pcs [maxLeaseStack]uintptr
+	n := runtime.Callers(2, pcs[:]) // Skip runtime.Callers and Acquire.
+	// Report the hold to the detector the lease releases it from.
+	d := pr.lockOrder.Load()
+	s := &leaseState{pr: pr, pcs: pcs[:n], onLeak: o.onLeak, lockOrder: d, owner: d.Owner()}
+	pr.lockAcquireReleaseMuFor(d)
+	l := &Lease{s: s}
+	runtime.AddCleanup(l, (*leaseState).leaked, s)
+	if o.timeout > 0 {
+		s.mu.Lock()
+		s.timer = time.AfterFunc(o.timeout, func() { s.finish(ErrLeaseExpired) })
+		s.mu.Unlock()
+	}
+	return l
+}
+
+// Value returns acquireReleaseValue, or the reason the lease has ended.
+func (l *Lease) Value() (int, error) {
+	s := l.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer runtime.KeepAlive(l) // Not leaked while in use.
+	if s.end != nil {
+		return 0, s.end
+	}
+	return s.value(), nil
+}
+
+// Set writes acquireReleaseValue, or returns the reason the lease has ended.
+func (l *Lease) Set(v int) error {
+	s := l.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer runtime.KeepAlive(l)
+	if s.end != nil {
+		return s.end
+	}
+	s.set(v)
+	return nil
+}
+
+// Release releases pr.acquireReleaseMu if the lease still holds it. Calling
+// it again, or after the lease expired, does nothing. Callers that have pr
+// should use pr.ReleaseLease, which checklocks checks.
+func (l *Lease) Release() {
+	l.s.finish(ErrLeaseReleased)
+	runtime.KeepAlive(l)
+}
+
+// ReleaseLease releases l, which must have been returned by pr.Acquire. It
+// is Lease.Release, annotated so that checklocks pairs it with Acquire.
+//
+// Whether the lock is still held, rather than reclaimed by the lease's
+// timeout, is only known at run time, so the body is not checked.
+// +checklocksrelease:pr.acquireReleaseMu
+// +checklocksignore
+func (pr *ProtectedResource) ReleaseLease(l *Lease) {
+	if l.s.pr != pr {
+		panic("resource: ReleaseLease: lease belongs to another resource")
+	}
+	l.Release()
+}
+
+// value reads acquireReleaseValue, which the lease holds the lock for.
+// +checklocksignore
+func (s *leaseState) value() int {
+	lockassert.Held(&s.pr.acquireReleaseMu)
+	return s.pr.acquireReleaseValue
+}
+
+// set writes acquireReleaseValue, which the lease holds the lock for.
+// +checklocksignore
+func (s *leaseState) set(v int) {
+	lockassert.Held(&s.pr.acquireReleaseMu)
+	old := s.pr.acquireReleaseValue
+	s.pr.acquireReleaseValue = v
+	s.pr.publish("acquireReleaseValue", old, v)
+}
+
+// finish ends the lease with err and releases pr.acquireReleaseMu, unless
+// the lease has already ended. It reports whether it ended the lease.
+func (s *leaseState) finish(err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.end != nil {
+		return false
+	}
+	s.end = err
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.unlock()
+	return true
+}
+
+// unlock releases pr.acquireReleaseMu, taken by Acquire. It is
+// unlockAcquireReleaseMu, except that the hold is released from the
+// lock-order detector on behalf of the acquiring goroutine: the timer and
+// the leak cleanup run on others.
+// +checklocksignore
+func (s *leaseState) unlock() {
+	pr := s.pr
+	lockassert.Held(&pr.acquireReleaseMu)
+	pr.metrics.Load().get(lockAcquireReleaseMu).released(time.Time{})
+	s.lockOrder.ReleaseFor(s.owner, lockClasses[lockAcquireReleaseMu])
+	pr.acquireReleaseMu.Unlock()
+}
+
+// leaked runs once the Lease is unreachable. If it was never released, it
+// reports the leak and releases the lock so the resource is usable again.
+func (s *leaseState) leaked() {
+	if s.finish(errLeaseLeaked) {
+		s.onLeak(formatStack(s.pcs))
+	}
+}
+
+// maxLeaseStack bounds the frames Acquire records for a leak report.
+const maxLeaseStack = 32
+
+// formatStack formats the frames of pcs as debug.Stack does, one function
+// per line followed by its indented file and line.
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			return b.String()
+		}
+	}
+}
+
+// reportLeakedLease is the default leak report.
+func reportLeakedLease(stack string) {
+	fmt.Fprintf(os.Stderr, "resource: Lease garbage collected without Release; acquireReleaseMu reclaimed. Acquired at:\n%s", stack)
+}
@@ -0,0 +1,165 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kakkoyun/checklocks-demo/pkg/lockorder"
+)
+
+func TestLease(t *testing.T) {
+	pr := newTestResource()
+	l := pr.Acquire()
+	if v, err := l.Value(); err != nil || v != 40 {
+		t.Errorf("Value() = %d, %v; want 40, nil", v, err)
+	}
+	if err := l.Set(41); err != nil {
+		t.Fatalf("Set(41) = %v", err)
+	}
+	if pr.acquireReleaseMu.TryLock() {
+		t.Fatal("acquireReleaseMu is free while the lease holds it")
+	}
+	pr.ReleaseLease(l)
+	l.Release() // Idempotent.
+
+	if v, err := l.Value(); !errors.Is(err, ErrLeaseReleased) {
+		t.Errorf("Value() after Release = %d, %v; want ErrLeaseReleased", v, err)
+	}
+	if err := l.Set(42); !errors.Is(err, ErrLeaseReleased) {
+		t.Errorf("Set(42) after Release = %v; want ErrLeaseReleased", err)
+	}
+	if v := pr.CallAcquireReleaseCorrect(); v != 1 {
+		t.Errorf("acquire/release after the lease read %d, want 1", v)
+	}
+}
+
+// Releasing l through other is the mistake under test; checklocks would
+// count it as releasing other's lock and report pr's as leaked.
+// +checklocksignore
+func TestReleaseLeaseOfAnotherResourcePanics(t *testing.T) {
+	pr, other := newTestResource(), newTestResource()
+	l := pr.Acquire()
+	defer pr.ReleaseLease(l)
+	defer func() {
+		if recover() == nil {
+			t.Error("ReleaseLease accepted another resource's lease")
+		}
+	}()
+	other.ReleaseLease(l)
+}
+
+func TestLeaseSetPublishes(t *testing.T) {
+	pr := newTestResource()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := pr.Watch(ctx)
+	l := pr.Acquire()
+	defer pr.ReleaseLease(l)
+	if err := l.Set(7); err != nil {
+		t.Fatalf("Set(7) = %v", err)
+	}
+	if c := <-ch; c.Field != "acquireReleaseValue" || c.Old != 40 || c.New != 7 {
+		t.Errorf("Set published %+v", c)
+	}
+}
+
+// The first lease is reclaimed by its timeout, which checklocks cannot
+// follow.
+// +checklocksignore
+func TestLeaseTimeout(t *testing.T) {
+	pr := newTestResource()
+	l := pr.Acquire(WithLeaseTimeout(10 * time.Millisecond))
+
+	next := pr.Acquire() // Blocks until the first lease expires.
+	defer next.Release()
+	if err := l.Set(1); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("Set on an expired lease = %v; want ErrLeaseExpired", err)
+	}
+	l.Release() // Must not release the lock now held by next.
+	if _, err := next.Value(); err != nil {
+		t.Errorf("Value() on the next lease = %v", err)
+	}
+	if pr.acquireReleaseMu.TryLock() {
+		t.Error("releasing an expired lease released its successor's lock")
+	}
+}
+
+// The lease is reclaimed by its timeout, which neither checklocks nor the
+// static lock-order check can follow.
+// +checklocksignore
+// +lockorderignore
+func TestLeaseTimeoutReleasesLockOrder(t *testing.T) {
+	d := lockorder.New(nil)
+	pr := newTestResource()
+	pr.TrackLockOrder(d)
+	// Record the declared order, so that a stale hold of acquireReleaseMu
+	// would close a cycle below.
+	pr.lockMu()
+	pr.lockAcquireReleaseMu()
+	pr.unlockAcquireReleaseMu()
+	pr.unlockMu()
+
+	l := pr.Acquire(WithLeaseTimeout(5 * time.Millisecond))
+	for {
+		if _, err := l.Value(); errors.Is(err, ErrLeaseExpired) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The timer released the lock on another goroutine; this one no longer
+	// holds it, so taking mu here is not an inversion.
+	pr.SetData(1, "after")
+	if _, err := pr.MarshalJSON(); err != nil {
+		t.Fatal(err)
+	}
+	if inv := d.Inversions(); len(inv) != 0 {
+		t.Errorf("%d inversion(s) reported after the lease expired: %v", len(inv), inv[0])
+	}
+}
+
+// The lease is leaked on purpose.
+// +checklocksignore
+func TestLeaseLeakReported(t *testing.T) {
+	pr := newTestResource()
+	stacks := make(chan string, 1)
+	func() {
+		_ = pr.Acquire(WithLeakReport(func(stack string) { stacks <- stack }))
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case stack := <-stacks:
+			if !strings.Contains(stack, "TestLeaseLeakReported") {
+				t.Errorf("leak report does not name the acquiring function:\n%s", stack)
+			}
+			l := pr.Acquire() // The leaked lease's lock was reclaimed.
+			l.Release()
+			return
+		case <-deadline:
+			t.Fatal("leaked lease was not reported")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestLeaseReleasedIsNotReported(t *testing.T) {
+	pr := newTestResource()
+	leaked := make(chan string, 1)
+	l := pr.Acquire(WithLeakReport(func(stack string) { leaked <- stack }))
+	pr.ReleaseLease(l)
+	for range 3 {
+		runtime.GC()
+	}
+	select {
+	case stack := <-leaked:
+		t.Errorf("released lease reported as leaked:\n%s", stack)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,21 @@
+package resource
+
+import "github.com/kakkoyun/checklocks-demo/pkg/lockorder"
+
+// lockClasses name the locks in the lock-order graph. Every
+// ProtectedResource shares these classes, so an order observed on one
+// resource is checked against every other.
+var lockClasses = [numLocks]string{
+	lockMu:               "ProtectedResource.mu",
+	lockRWMu:             "ProtectedResource.rwMu",
+	lockAcquireReleaseMu: "ProtectedResource.acquireReleaseMu",
+}
+
+// TrackLockOrder reports every acquisition and release of mu, rwMu and
+// acquireReleaseMu to d, which flags acquisition orders that could deadlock.
+// Share one Detector across resources, and across other code using it, to
+// build a single global graph. Passing nil turns tracking off. Locks held
+// when tracking is switched are not tracked until they are re-acquired.
+func (pr *ProtectedResource) TrackLockOrder(d *lockorder.Detector) {
+	pr.lockOrder.Store(d)
+}
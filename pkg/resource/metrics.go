@@ -0,0 +1,351 @@
+package resource
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kakkoyun/checklocks-demo/internal/lockassert"
+	"github.com/kakkoyun/checklocks-demo/pkg/lockorder"
+)
+
+// lockID identifies one of the three mutexes in a ProtectedResource.
+type lockID int
+
+const (
+	lockMu lockID = iota
+	lockRWMu
+	lockAcquireReleaseMu
+	numLocks
+)
+
+// lockNames are the names reported in Stats and in the Prometheus output.
+var lockNames = [numLocks]string{
+	lockMu:               "mu",
+	lockRWMu:             "rwMu",
+	lockAcquireReleaseMu: "acquireReleaseMu",
+}
+
+// histogramBounds are the upper bounds of the wait/hold histogram buckets.
+// Durations above the last bound only land in the implicit +Inf bucket.
+var histogramBounds = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// histogram is a fixed-bucket duration histogram. It is not safe for
+// concurrent use; lockStats guards it with its own mutex.
+type histogram struct {
+	counts [9]uint64 // len(histogramBounds) + 1 for +Inf
+	count  uint64
+	sum    time.Duration
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(histogramBounds) && d > histogramBounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += d
+}
+
+func (h *histogram) snapshot() Histogram {
+	s := Histogram{
+		Buckets: make([]Bucket, len(histogramBounds)),
+		Count:   h.count,
+		Sum:     h.sum,
+	}
+	var cumulative uint64
+	for i, bound := range histogramBounds {
+		cumulative += h.counts[i]
+		s.Buckets[i] = Bucket{UpperBound: bound, Count: cumulative}
+	}
+	return s
+}
+
+// lockStats accumulates measurements for a single named lock.
+// All methods are safe to call on a nil receiver, which records nothing.
+type lockStats struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	acquisitions uint64
+	// +checklocks:mu
+	wait histogram
+	// +checklocks:mu
+	hold histogram
+	// +checklocks:mu
+	maxHold time.Duration
+	// heldSince is the acquisition time of the current exclusive holder.
+	// +checklocks:mu
+	heldSince time.Time
+}
+
+// now returns the current time, or the zero time when s is nil so callers
+// don't pay for a clock read with metrics disabled.
+func (s *lockStats) now() time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// acquired records a successful acquisition that started waiting at start.
+// For exclusive locks it also remembers when the hold began.
+func (s *lockStats) acquired(start time.Time, exclusive bool) time.Time {
+	if s == nil || start.IsZero() {
+		return time.Time{}
+	}
+	now := time.Now()
+	s.mu.Lock()
+	s.acquisitions++
+	s.wait.observe(now.Sub(start))
+	if exclusive {
+		s.heldSince = now
+	}
+	s.mu.Unlock()
+	return now
+}
+
+// released records the end of a hold that began at heldSince. A zero
+// heldSince means the current exclusive holder's start time is used.
+func (s *lockStats) released(heldSince time.Time) {
+	if s == nil {
+		return
+	}
+	now := time.Now()
+	s.mu.Lock()
+	if heldSince.IsZero() {
+		heldSince = s.heldSince
+		s.heldSince = time.Time{}
+	}
+	if !heldSince.IsZero() {
+		d := now.Sub(heldSince)
+		s.hold.observe(d)
+		s.maxHold = max(s.maxHold, d)
+	}
+	s.mu.Unlock()
+}
+
+func (s *lockStats) snapshot(name string) LockStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return LockStats{
+		Name:         name,
+		Acquisitions: s.acquisitions,
+		Wait:         s.wait.snapshot(),
+		Hold:         s.hold.snapshot(),
+		MaxHold:      s.maxHold,
+	}
+}
+
+// lockMetrics holds the stats for every lock of one ProtectedResource.
+type lockMetrics struct {
+	locks [numLocks]lockStats
+}
+
+// get returns the stats for id, or nil when metrics are disabled.
+func (m *lockMetrics) get(id lockID) *lockStats {
+	if m == nil {
+		return nil
+	}
+	return &m.locks[id]
+}
+
+// Bucket is a cumulative histogram bucket: Count observations were <= UpperBound.
+type Bucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// Histogram is a point-in-time copy of a duration histogram.
+type Histogram struct {
+	Buckets []Bucket
+	Count   uint64
+	Sum     time.Duration
+}
+
+// LockStats is a point-in-time copy of the measurements for one lock.
+type LockStats struct {
+	Name         string
+	Acquisitions uint64
+	Wait         Histogram
+	Hold         Histogram
+	MaxHold      time.Duration
+}
+
+// EnableLockMetrics turns on wait-time and hold-time recording for mu, rwMu
+// and acquireReleaseMu. It is a no-op if metrics are already enabled.
+// Holds that began before metrics were enabled are not recorded.
+func (pr *ProtectedResource) EnableLockMetrics() {
+	pr.metrics.CompareAndSwap(nil, &lockMetrics{})
+}
+
+// Stats returns a snapshot of the lock metrics, one entry per lock in a fixed
+// order. It returns nil if EnableLockMetrics has not been called.
+func (pr *ProtectedResource) Stats() []LockStats {
+	m := pr.metrics.Load()
+	if m == nil {
+		return nil
+	}
+	stats := make([]LockStats, numLocks)
+	for id := range numLocks {
+		stats[id] = m.locks[id].snapshot(lockNames[id])
+	}
+	return stats
+}
+
+// WritePrometheus writes the lock metrics to w in the Prometheus text
+// exposition format. It writes nothing if metrics are disabled.
+func (pr *ProtectedResource) WritePrometheus(w io.Writer) error {
+	stats := pr.Stats()
+	if stats == nil {
+		return nil
+	}
+	ew := &errWriter{w: w}
+	ew.printf("# HELP resource_lock_acquisitions_total Number of times the lock was acquired.\n")
+	ew.printf("# TYPE resource_lock_acquisitions_total counter\n")
+	for _, s := range stats {
+		ew.printf("resource_lock_acquisitions_total{lock=%q} %d\n", s.Name, s.Acquisitions)
+	}
+	writePrometheusHistogram(ew, "resource_lock_wait_seconds", "Time spent waiting to acquire the lock.", stats, func(s LockStats) Histogram { return s.Wait })
+	writePrometheusHistogram(ew, "resource_lock_hold_seconds", "Time the lock was held.", stats, func(s LockStats) Histogram { return s.Hold })
+	ew.printf("# HELP resource_lock_max_hold_seconds Longest observed hold of the lock.\n")
+	ew.printf("# TYPE resource_lock_max_hold_seconds gauge\n")
+	for _, s := range stats {
+		ew.printf("resource_lock_max_hold_seconds{lock=%q} %g\n", s.Name, s.MaxHold.Seconds())
+	}
+	return ew.err
+}
+
+func writePrometheusHistogram(ew *errWriter, name, help string, stats []LockStats, pick func(LockStats) Histogram) {
+	ew.printf("# HELP %s %s\n", name, help)
+	ew.printf("# TYPE %s histogram\n", name)
+	for _, s := range stats {
+		h := pick(s)
+		for _, b := range h.Buckets {
+			ew.printf("%s_bucket{lock=%q,le=\"%g\"} %d\n", name, s.Name, b.UpperBound.Seconds(), b.Count)
+		}
+		ew.printf("%s_bucket{lock=%q,le=\"+Inf\"} %d\n", name, s.Name, h.Count)
+		ew.printf("%s_sum{lock=%q} %g\n", name, s.Name, h.Sum.Seconds())
+		ew.printf("%s_count{lock=%q} %d\n", name, s.Name, h.Count)
+	}
+}
+
+// errWriter remembers the first write error so the exposition code can stay linear.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...any) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
+
+// --- Instrumented Lock Helpers ---
+//
+// Each helper records metrics when EnableLockMetrics was called and reports
+// to the lock-order detector when TrackLockOrder was called. Acquisition is
+// reported to the detector before blocking, so an inversion that really
+// deadlocks is still reported.
+
+// lockMu acquires pr.mu.
+// +checklocksacquire:pr.mu
+func (pr *ProtectedResource) lockMu() {
+	s := pr.metrics.Load().get(lockMu)
+	pr.lockOrder.Load().Acquire(lockClasses[lockMu])
+	start := s.now()
+	pr.mu.Lock()
+	s.acquired(start, true)
+	lockassert.Held(&pr.mu)
+}
+
+// unlockMu releases pr.mu.
+// +checklocksrelease:pr.mu
+func (pr *ProtectedResource) unlockMu() {
+	lockassert.Held(&pr.mu)
+	pr.metrics.Load().get(lockMu).released(time.Time{})
+	pr.lockOrder.Load().Release(lockClasses[lockMu])
+	pr.mu.Unlock()
+}
+
+// lockRWMu write-acquires pr.rwMu.
+// +checklocksacquire:pr.rwMu
+func (pr *ProtectedResource) lockRWMu() {
+	s := pr.metrics.Load().get(lockRWMu)
+	pr.lockOrder.Load().Acquire(lockClasses[lockRWMu])
+	start := s.now()
+	pr.rwMu.Lock()
+	s.acquired(start, true)
+	lockassert.WHeld(&pr.rwMu)
+}
+
+// unlockRWMu write-releases pr.rwMu.
+// +checklocksrelease:pr.rwMu
+func (pr *ProtectedResource) unlockRWMu() {
+	lockassert.WHeld(&pr.rwMu)
+	pr.metrics.Load().get(lockRWMu).released(time.Time{})
+	pr.lockOrder.Load().Release(lockClasses[lockRWMu])
+	pr.rwMu.Unlock()
+}
+
+// rlockRWMu read-acquires pr.rwMu. Read holds overlap, so the returned start
+// time must be passed to runlockRWMu.
+// +checklocksacquireread:pr.rwMu
+func (pr *ProtectedResource) rlockRWMu() time.Time {
+	s := pr.metrics.Load().get(lockRWMu)
+	pr.lockOrder.Load().Acquire(lockClasses[lockRWMu])
+	start := s.now()
+	pr.rwMu.RLock()
+	lockassert.RHeld(&pr.rwMu)
+	return s.acquired(start, false)
+}
+
+// runlockRWMu read-releases pr.rwMu.
+// +checklocksreleaseread:pr.rwMu
+func (pr *ProtectedResource) runlockRWMu(heldSince time.Time) {
+	lockassert.RHeld(&pr.rwMu)
+	if !heldSince.IsZero() {
+		pr.metrics.Load().get(lockRWMu).released(heldSince)
+	}
+	pr.lockOrder.Load().Release(lockClasses[lockRWMu])
+	pr.rwMu.RUnlock()
+}
+
+// lockAcquireReleaseMu acquires pr.acquireReleaseMu.
+// +checklocksacquire:pr.acquireReleaseMu
+func (pr *ProtectedResource) lockAcquireReleaseMu() {
+	pr.lockAcquireReleaseMuFor(pr.lockOrder.Load())
+}
+
+// lockAcquireReleaseMuFor is lockAcquireReleaseMu, reporting the hold to d,
+// a detector the caller has already loaded from pr.lockOrder.
+// +checklocksacquire:pr.acquireReleaseMu
+func (pr *ProtectedResource) lockAcquireReleaseMuFor(d *lockorder.Detector) {
+	s := pr.metrics.Load().get(lockAcquireReleaseMu)
+	d.Acquire(lockClasses[lockAcquireReleaseMu])
+	start := s.now()
+	pr.acquireReleaseMu.Lock()
+	s.acquired(start, true)
+	lockassert.Held(&pr.acquireReleaseMu)
+}
+
+// unlockAcquireReleaseMu releases pr.acquireReleaseMu.
+// +checklocksrelease:pr.acquireReleaseMu
+func (pr *ProtectedResource) unlockAcquireReleaseMu() {
+	lockassert.Held(&pr.acquireReleaseMu)
+	pr.metrics.Load().get(lockAcquireReleaseMu).released(time.Time{})
+	pr.lockOrder.Load().Release(lockClasses[lockAcquireReleaseMu])
+	pr.acquireReleaseMu.Unlock()
+}
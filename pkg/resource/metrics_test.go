@@ -0,0 +1,94 @@
+package resource
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatsDisabledByDefault(t *testing.T) {
+	pr := newTestResource()
+	pr.SetData(1, "no metrics")
+	if stats := pr.Stats(); stats != nil {
+		t.Errorf("Stats without EnableLockMetrics: expected nil, got %+v", stats)
+	}
+	var sb strings.Builder
+	if err := pr.WritePrometheus(&sb); err != nil || sb.Len() != 0 {
+		t.Errorf("WritePrometheus without metrics: expected no output, got %q (err %v)", sb.String(), err)
+	}
+}
+
+func TestStatsCountsAcquisitions(t *testing.T) {
+	pr := newTestResource()
+	pr.EnableLockMetrics()
+
+	pr.SetData(1, "one")
+	_, _ = pr.GetData()
+	_ = pr.GetReadGuardedValueCorrect()
+	_ = pr.CallAcquireReleaseCorrect()
+
+	want := map[string]uint64{"mu": 2, "rwMu": 1, "acquireReleaseMu": 1}
+	for _, s := range pr.Stats() {
+		if s.Acquisitions != want[s.Name] {
+			t.Errorf("lock %s: expected %d acquisitions, got %d", s.Name, want[s.Name], s.Acquisitions)
+		}
+		if s.Wait.Count != s.Acquisitions || s.Hold.Count != s.Acquisitions {
+			t.Errorf("lock %s: expected %d wait/hold observations, got %d/%d", s.Name, s.Acquisitions, s.Wait.Count, s.Hold.Count)
+		}
+	}
+}
+
+func TestStatsRecordsHoldAndWait(t *testing.T) {
+	pr := newTestResource()
+	pr.EnableLockMetrics()
+
+	const hold = 20 * time.Millisecond
+	var wg sync.WaitGroup
+	pr.lockMu()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pr.SetData(2, "waited") // Blocks until the hold below ends.
+	}()
+	time.Sleep(hold)
+	pr.unlockMu()
+	wg.Wait()
+
+	s := pr.Stats()[lockMu]
+	if s.MaxHold < hold {
+		t.Errorf("MaxHold: expected at least %v, got %v", hold, s.MaxHold)
+	}
+	if s.Wait.Sum <= 0 {
+		t.Errorf("Wait.Sum: expected a positive wait, got %v", s.Wait.Sum)
+	}
+	last := s.Hold.Buckets[len(s.Hold.Buckets)-1]
+	if last.Count != s.Hold.Count {
+		t.Errorf("Hold buckets not cumulative: last bucket %d, count %d", last.Count, s.Hold.Count)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	pr := newTestResource()
+	pr.EnableLockMetrics()
+	pr.SetData(1, "one")
+
+	var sb strings.Builder
+	if err := pr.WritePrometheus(&sb); err != nil {
+		t.Fatalf("WritePrometheus failed: %v", err)
+	}
+	out := sb.String()
+	for _, want := range []string{
+		"# TYPE resource_lock_acquisitions_total counter\n",
+		`resource_lock_acquisitions_total{lock="mu"} 1` + "\n",
+		`resource_lock_acquisitions_total{lock="rwMu"} 0` + "\n",
+		"# TYPE resource_lock_hold_seconds histogram\n",
+		`resource_lock_hold_seconds_bucket{lock="mu",le="+Inf"} 1` + "\n",
+		`resource_lock_wait_seconds_count{lock="acquireReleaseMu"} 0` + "\n",
+		"# TYPE resource_lock_max_hold_seconds gauge\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WritePrometheus output missing %q", want)
+		}
+	}
+}
@@ -5,15 +5,28 @@ import (
 	"sync/atomic"
 
 	"github.com/trailofbits/go-mutexasserts"
+
+	"github.com/kakkoyun/checklocks-demo/internal/lockassert"
+	"github.com/kakkoyun/checklocks-demo/internal/watch"
+	"github.com/kakkoyun/checklocks-demo/pkg/lockorder"
 )
 
 // ProtectedResource demonstrates a resource with some fields guarded by a mutex.
+// Methods that hold more than one lock take them in the declared order.
+// +lockorder:mu<rwMu<acquireReleaseMu
 type ProtectedResource struct {
 	mu sync.Mutex
 	// +checklocks:mu
 	value int
 	// +checklocks:mu
 	description string
+	// Written atomically with mu held, so watchers can stamp changes to
+	// fields outside mu with it.
+	// +checkatomic
+	// +checklocks:mu
+	version uint64 // Incremented on every commit to value or description.
+	// +checklocks:mu
+	changed chan struct{} // Closed on the next commit; see WaitUntil.
 
 	id string // This field is not guarded by mu
 
@@ -31,6 +44,11 @@ type ProtectedResource struct {
 	acquireReleaseMu sync.Mutex
 	// +checklocks:acquireReleaseMu
 	acquireReleaseValue int
+
+	metrics   atomic.Pointer[lockMetrics]        // nil unless EnableLockMetrics was called
+	lockOrder atomic.Pointer[lockorder.Detector] // nil unless TrackLockOrder was called
+	watchers  watch.Hub[Change]
+	wal       *wal // nil unless created by Open
 }
 
 // NewProtectedResource creates a new ProtectedResource.
@@ -48,10 +66,9 @@ func NewProtectedResource(initialValue, initialReadValue, initialAcqRelValue int
 
 // SetData correctly locks the mutex before writing to the guarded fields.
 func (pr *ProtectedResource) SetData(val int, desc string) {
-	pr.mu.Lock()
-	pr.value = val
-	pr.description = desc
-	pr.mu.Unlock()
+	pr.lockMu()
+	pr.setDataLocked(val, desc)
+	pr.unlockMu()
 }
 
 // IncorrectSetData incorrectly writes to the guarded fields without locking.
@@ -63,10 +80,10 @@ func (pr *ProtectedResource) IncorrectSetData(val int, desc string) {
 
 // GetData correctly locks the mutex before reading the guarded fields.
 func (pr *ProtectedResource) GetData() (int, string) {
-	pr.mu.Lock()
+	pr.lockMu()
 	v := pr.value
 	d := pr.description
-	pr.mu.Unlock()
+	pr.unlockMu()
 	return v, d
 }
 
@@ -74,15 +91,24 @@ func (pr *ProtectedResource) GetData() (int, string) {
 // The +checklocks annotation enforces this assumption.
 // +checklocks:pr.mu
 func (pr *ProtectedResource) setDataLocked(val int, desc string) {
+	lockassert.Held(&pr.mu)
+	if !pr.wal.logSetData(val, desc) {
+		return // The log is broken or closed; see Err.
+	}
+	oldVal, oldDesc := pr.value, pr.description
 	pr.value = val
 	pr.description = desc
+	atomic.AddUint64(&pr.version, 1)
+	pr.notifyLocked()
+	pr.publish("value", oldVal, val)
+	pr.publish("description", oldDesc, desc)
 }
 
 // SetDataWithHelper demonstrates calling an annotated function correctly (lock held).
 func (pr *ProtectedResource) SetDataWithHelper(val int, desc string) {
-	pr.mu.Lock()
+	pr.lockMu()
 	pr.setDataLocked(val, desc) // Correct: Lock 'pr.mu' is held.
-	pr.mu.Unlock()
+	pr.unlockMu()
 }
 
 // IncorrectSetDataWithHelper demonstrates calling an annotated function incorrectly (lock not held).
@@ -98,16 +124,18 @@ func (pr *ProtectedResource) GetID() string {
 
 // SetID sets the unguarded ID field. No lock is needed.
 func (pr *ProtectedResource) SetID(newID string) {
-	pr.id = newID // Correct: No lock needed for unguarded field.
+	pr.wal.logSetID(newID, func() {
+		pr.id = newID // Correct: No lock needed for unguarded field.
+	})
 }
 
 // --- RWMutex and Read Locks ---
 
 // GetReadGuardedValueCorrect correctly acquires the read lock.
 func (pr *ProtectedResource) GetReadGuardedValueCorrect() int {
-	pr.rwMu.RLock()
+	held := pr.rlockRWMu()
 	v := pr.readGuardedValue
-	pr.rwMu.RUnlock()
+	pr.runlockRWMu(held)
 	return v
 }
 
@@ -119,14 +147,15 @@ func (pr *ProtectedResource) GetReadGuardedValueIncorrect() int {
 // readDataRLocked requires the caller to hold at least the read lock.
 // +checklocksread:pr.rwMu
 func (pr *ProtectedResource) readDataRLocked() int {
+	lockassert.RHeld(&pr.rwMu)
 	return pr.readGuardedValue // Correct: Read lock is assumed held by annotation.
 }
 
 // CallReadDataRLockedCorrect calls an annotated function correctly (RLock held).
 func (pr *ProtectedResource) CallReadDataRLockedCorrect() int {
-	pr.rwMu.RLock()
+	held := pr.rlockRWMu()
 	v := pr.readDataRLocked() // Correct: Lock 'pr.rwMu' is read-held.
-	pr.rwMu.RUnlock()
+	pr.runlockRWMu(held)
 	return v
 }
 
@@ -139,7 +168,9 @@ func (pr *ProtectedResource) CallReadDataRLockedIncorrect() int {
 
 // IncrementAtomicCorrect uses atomic operations on an atomic-only field.
 func (pr *ProtectedResource) IncrementAtomicCorrect() {
-	atomic.AddInt32(&pr.atomicValue, 1) // Correct: Atomic operation on atomic field.
+	pr.wal.logAddAtomic(1, func() {
+		atomic.AddInt32(&pr.atomicValue, 1) // Correct: Atomic operation on atomic field.
+	})
 }
 
 // ReadAtomicCorrect uses atomic operations on an atomic-only field.
@@ -166,17 +197,21 @@ func (pr *ProtectedResource) ReadMixedCorrectAtomic() int32 {
 
 // ReadMixedCorrectLock reads a mixed field with the lock held (allowed for reads).
 func (pr *ProtectedResource) ReadMixedCorrectLock() int32 {
-	pr.mu.Lock()
+	pr.lockMu()
 	v := pr.mixedValue // Correct: Lock is held for read.
-	pr.mu.Unlock()
+	pr.unlockMu()
 	return v
 }
 
 // WriteMixedCorrect writes a mixed field atomically with the lock held (required for writes).
 func (pr *ProtectedResource) WriteMixedCorrect(v int32) {
-	pr.mu.Lock()
-	atomic.StoreInt32(&pr.mixedValue, v) // Correct: Lock is held and write is atomic.
-	pr.mu.Unlock()
+	pr.lockMu()
+	if pr.wal.logStoreMixed(v) {
+		old := pr.mixedValue
+		atomic.StoreInt32(&pr.mixedValue, v) // Correct: Lock is held and write is atomic.
+		pr.publish("mixedValue", old, v)
+	}
+	pr.unlockMu()
 }
 
 // WriteMixedIncorrectAtomicOnly writes a mixed field atomically *without* the lock.
@@ -200,21 +235,26 @@ func (pr *ProtectedResource) WriteMixedIncorrectNeither(v int32) {
 
 // --- Acquire/Release ---
 
-// AcquireAndSet acquires the lock and sets the value.
+// AcquireAndSet acquires the lock and sets the value. Nothing at runtime
+// notices a missing GetAndRelease; Acquire returns a Lease that does.
 // +checklocksacquire:pr.acquireReleaseMu
 func (pr *ProtectedResource) AcquireAndSet(v int) {
 	// Annotation requires lock NOT be held on entry.
-	pr.acquireReleaseMu.Lock() // Acquires the lock.
+	pr.lockAcquireReleaseMu() // Acquires the lock.
+	old := pr.acquireReleaseValue
 	pr.acquireReleaseValue = v
+	pr.publish("acquireReleaseValue", old, v)
 	// Annotation implies lock IS held on exit.
+	lockassert.Held(&pr.acquireReleaseMu)
 }
 
 // GetAndRelease reads the value and releases the lock.
 // +checklocksrelease:pr.acquireReleaseMu
 func (pr *ProtectedResource) GetAndRelease() int {
+	lockassert.Held(&pr.acquireReleaseMu)
 	// Annotation requires lock BE held on entry.
 	v := pr.acquireReleaseValue
-	pr.acquireReleaseMu.Unlock() // Releases the lock.
+	pr.unlockAcquireReleaseMu() // Releases the lock.
 	// Annotation implies lock IS NOT held on exit.
 	return v
 }
@@ -285,7 +325,168 @@ func (pr *ProtectedResource) helperCalledUnderLock() {
 
 // CallHelperUnderLockCorrectly demonstrates calling the ignored helper correctly.
 func (pr *ProtectedResource) CallHelperUnderLockCorrectly() {
-	pr.mu.Lock()
+	pr.lockMu()
 	pr.helperCalledUnderLock()
-	pr.mu.Unlock()
+	pr.unlockMu()
+}
+
+// --- Closure-based Transactions ---
+
+// LockedView gives an Update callback read/write access to the fields guarded
+// by pr.mu. It is only valid for the duration of the callback. Writes to
+// value and description are visible to the callback at once but only
+// committed, and published to watchers, when it returns nil; the mixed
+// field is staged in the view until then.
+//
+// checklocks does not carry pr.mu into the callback, a closure, so the
+// view's methods are +checklocksignore and assert the lock at entry instead.
+type LockedView struct {
+	pr      *ProtectedResource
+	setVal  bool  // Set by SetValue.
+	setDesc bool  // Set by SetDescription.
+	setMix  bool  // Set by SetMixedValue.
+	mixed   int32 // Staged by SetMixedValue.
+}
+
+// Value returns the guarded value.
+// +checklocksignore
+func (v *LockedView) Value() int {
+	lockassert.Held(&v.pr.mu)
+	return v.pr.value
+}
+
+// Description returns the guarded description.
+// +checklocksignore
+func (v *LockedView) Description() string {
+	lockassert.Held(&v.pr.mu)
+	return v.pr.description
+}
+
+// MixedValue returns the mixed field, or the value staged by SetMixedValue.
+// Reads are allowed with the lock held.
+// +checklocksignore
+func (v *LockedView) MixedValue() int32 {
+	lockassert.Held(&v.pr.mu)
+	if v.setMix {
+		return v.mixed
+	}
+	return v.pr.mixedValue
+}
+
+// SetValue writes the guarded value.
+// +checklocksignore
+func (v *LockedView) SetValue(val int) {
+	lockassert.Held(&v.pr.mu)
+	v.pr.value = val
+	v.setVal = true
+}
+
+// SetDescription writes the guarded description.
+// +checklocksignore
+func (v *LockedView) SetDescription(desc string) {
+	lockassert.Held(&v.pr.mu)
+	v.pr.description = desc
+	v.setDesc = true
+}
+
+// SetMixedValue stages a write of the mixed field, stored atomically when
+// the callback commits.
+// +checklocksignore
+func (v *LockedView) SetMixedValue(val int32) {
+	lockassert.Held(&v.pr.mu)
+	v.mixed = val
+	v.setMix = true
+}
+
+// ReadOnlyView gives a View callback read access to the fields guarded by
+// pr.mu. It is only valid for the duration of the callback. Like
+// LockedView's, its methods assert the lock instead of being checked.
+type ReadOnlyView struct {
+	pr *ProtectedResource
+}
+
+// Value returns the guarded value.
+// +checklocksignore
+func (v ReadOnlyView) Value() int {
+	lockassert.Held(&v.pr.mu)
+	return v.pr.value
+}
+
+// Description returns the guarded description.
+// +checklocksignore
+func (v ReadOnlyView) Description() string {
+	lockassert.Held(&v.pr.mu)
+	return v.pr.description
+}
+
+// MixedValue returns the mixed field. Reads are allowed with the lock held.
+// +checklocksignore
+func (v ReadOnlyView) MixedValue() int32 {
+	lockassert.Held(&v.pr.mu)
+	return v.pr.mixedValue
+}
+
+// Update runs fn with pr.mu held, so a read-modify-write of value and
+// description happens under a single lock acquisition. If fn returns an
+// error, everything it wrote through the view is rolled back and the error
+// is returned; otherwise the writes are committed, the version is bumped
+// once if fn wrote value or description, and watchers are notified.
+//
+// If fn panics, its writes are rolled back too and the panic continues.
+//
+// For a resource created by Open, value and description are logged as one
+// record at commit, and the mixed field as another; if logging value and
+// description fails nothing is committed and the log error is returned.
+func (pr *ProtectedResource) Update(fn func(*LockedView) error) error {
+	pr.lockMu()
+	defer pr.unlockMu()
+	oldVal, oldDesc := pr.value, pr.description
+	defer pr.rollbackOnPanic(oldVal, oldDesc)
+	v := &LockedView{pr: pr}
+	if err := fn(v); err != nil {
+		pr.value, pr.description = oldVal, oldDesc
+		return err
+	}
+	if v.setVal || v.setDesc {
+		if !pr.wal.logSetData(pr.value, pr.description) {
+			pr.value, pr.description = oldVal, oldDesc
+			return pr.Err()
+		}
+		atomic.AddUint64(&pr.version, 1)
+		pr.notifyLocked()
+		if v.setVal {
+			pr.publish("value", oldVal, pr.value)
+		}
+		if v.setDesc {
+			pr.publish("description", oldDesc, pr.description)
+		}
+	}
+	if v.setMix {
+		if !pr.wal.logStoreMixed(v.mixed) {
+			return pr.Err()
+		}
+		old := pr.mixedValue
+		atomic.StoreInt32(&pr.mixedValue, v.mixed)
+		pr.publish("mixedValue", old, v.mixed)
+	}
+	return nil
+}
+
+// rollbackOnPanic restores value and description if Update's callback
+// panicked, then re-panics. Update defers it directly, as recover requires,
+// after pr.lockMu, so it runs before the lock is released.
+// +checklocks:pr.mu
+func (pr *ProtectedResource) rollbackOnPanic(val int, desc string) {
+	lockassert.Held(&pr.mu)
+	if r := recover(); r != nil {
+		pr.value, pr.description = val, desc
+		panic(r)
+	}
+}
+
+// View runs fn with pr.mu held, giving it a consistent read of the guarded fields.
+func (pr *ProtectedResource) View(fn func(ReadOnlyView)) {
+	pr.lockMu()
+	defer pr.unlockMu()
+	fn(ReadOnlyView{pr: pr})
 }
@@ -1,7 +1,13 @@
 package resource
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
+
+	"github.com/kakkoyun/checklocks-demo/internal/lockassert"
 )
 
 // Helper to create a new resource for tests, updated for new fields
@@ -39,6 +45,9 @@ func TestCorrectSetDataWithHelper(t *testing.T) {
 
 // TestIncorrectSetDataWithHelper expects a checklocks failure for calling the locked helper without locking.
 func TestIncorrectSetDataWithHelper(t *testing.T) {
+	if lockassert.Enabled {
+		t.Skip("Skipping test: the debug build's runtime lock assertion exits the process.")
+	}
 	pr := newTestResource()
 	pr.IncorrectSetDataWithHelper(4, "bad helper update") // Linter should report violation within IncorrectSetDataWithHelper
 }
@@ -46,9 +55,13 @@ func TestIncorrectSetDataWithHelper(t *testing.T) {
 // TestDirectCallToSetDataLocked expects a checklocks failure for calling the locked helper directly without the lock.
 // Now we can call the unexported method directly.
 func TestDirectCallToSetDataLocked(t *testing.T) {
+	if lockassert.Enabled {
+		t.Skip("Skipping test: the debug build's runtime lock assertion exits the process.")
+	}
 	pr := newTestResource()
-	// Directly call the unexported method requiring a lock, without holding it.
-	pr.setDataLocked(5, "direct bad update") // +checklocksfail expected direct call violation on unexported annotated function setDataLocked
+	// Directly call the unexported method requiring a lock, without holding
+	// it. checklocks must report the call; the annotation expects that.
+	pr.setDataLocked(5, "direct bad update") // +checklocksfail
 }
 
 // TestIDAccess verifies that accessing the unguarded ID field works without locks and without analyzer errors.
@@ -88,6 +101,9 @@ func TestChecklocksReadCorrect(t *testing.T) {
 
 // TestChecklocksReadIncorrect expects a checklocks failure for reading using readDataRLocked without locking.
 func TestChecklocksReadIncorrect(t *testing.T) {
+	if lockassert.Enabled {
+		t.Skip("Skipping test: the debug build's runtime lock assertion exits the process.")
+	}
 	pr := newTestResource()
 	_ = pr.CallReadDataRLockedIncorrect() // Linter should report violation within CallReadDataRLockedIncorrect
 }
@@ -273,3 +289,125 @@ func TestHelperUnderLockAssertionIncorrect(t *testing.T) {
 	// pr.helperCalledUnderLock() // This call should trigger exit(1) with -tags debug
 	// t.Log("This line should NOT be reached if assertion fired.")
 }
+
+// --- Update/View Tests ---
+
+func TestUpdateCompareAndSet(t *testing.T) {
+	pr := newTestResource()
+	errMismatch := errors.New("value mismatch")
+	cas := func(old, new int) error {
+		return pr.Update(func(v *LockedView) error {
+			if v.Value() != old {
+				return errMismatch
+			}
+			v.SetValue(new)
+			v.SetDescription(fmt.Sprintf("set to %d", new))
+			return nil
+		})
+	}
+
+	if err := cas(0, 1); err != nil {
+		t.Fatalf("Update with matching value failed: %v", err)
+	}
+	if err := cas(0, 2); !errors.Is(err, errMismatch) {
+		t.Fatalf("Update with stale value: expected errMismatch, got %v", err)
+	}
+	val, desc := pr.GetData()
+	if val != 1 || desc != "set to 1" {
+		t.Errorf("Update failed: expected 1/set to 1, got %d/%s", val, desc)
+	}
+}
+
+func TestUpdateErrorRollsBack(t *testing.T) {
+	pr := newTestResource()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := pr.Watch(ctx)
+	errAbort := errors.New("abort")
+
+	err := pr.Update(func(v *LockedView) error {
+		v.SetValue(7)
+		v.SetDescription("aborted")
+		v.SetMixedValue(99)
+		if v.Value() != 7 || v.MixedValue() != 99 {
+			t.Errorf("view does not read its own writes: %d, %d", v.Value(), v.MixedValue())
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Update returned %v, want errAbort", err)
+	}
+	if val, desc := pr.GetData(); val != 0 || desc != "initial" {
+		t.Errorf("aborted Update left %d/%s, want 0/initial", val, desc)
+	}
+	if mixed := pr.ReadMixedCorrectAtomic(); mixed != 30 {
+		t.Errorf("aborted Update left mixedValue %d, want 30", mixed)
+	}
+	if s := pr.Snapshot(); s.Version != 0 {
+		t.Errorf("aborted Update bumped the version to %d", s.Version)
+	}
+	select {
+	case c := <-ch:
+		t.Errorf("aborted Update published %+v", c)
+	default:
+	}
+}
+
+func TestUpdatePanicRollsBack(t *testing.T) {
+	pr := newTestResource()
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v, want the callback's panic", r)
+			}
+		}()
+		_ = pr.Update(func(v *LockedView) error {
+			v.SetValue(7)
+			v.SetDescription("panicked")
+			panic("boom")
+		})
+	}()
+	// GetData would block if the panic had left pr.mu locked.
+	if val, desc := pr.GetData(); val != 0 || desc != "initial" {
+		t.Errorf("panicking Update left %d/%s, want 0/initial", val, desc)
+	}
+	if s := pr.Snapshot(); s.Version != 0 {
+		t.Errorf("panicking Update bumped the version to %d", s.Version)
+	}
+}
+
+func TestUpdateConcurrentIncrement(t *testing.T) {
+	pr := newTestResource()
+	const n = 50
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = pr.Update(func(v *LockedView) error {
+				v.SetValue(v.Value() + 1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	if val, _ := pr.GetData(); val != n {
+		t.Errorf("Concurrent Update lost writes: expected %d, got %d", n, val)
+	}
+}
+
+func TestView(t *testing.T) {
+	pr := newTestResource()
+	pr.WriteMixedCorrect(31)
+	var (
+		val   int
+		desc  string
+		mixed int32
+	)
+	pr.View(func(v ReadOnlyView) {
+		val, desc, mixed = v.Value(), v.Description(), v.MixedValue()
+	})
+	if val != 0 || desc != "initial" || mixed != 31 {
+		t.Errorf("View failed: expected 0/initial/31, got %d/%s/%d", val, desc, mixed)
+	}
+}
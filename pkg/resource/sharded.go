@@ -0,0 +1,156 @@
+package resource
+
+import (
+	"hash/maphash"
+	"sync"
+
+	"github.com/kakkoyun/checklocks-demo/internal/lockassert"
+)
+
+// --- Sharded Resource ---
+
+// Sharded is a keyed store of value/description pairs for write-heavy
+// workloads. Where every write to a ProtectedResource serializes on pr.mu,
+// Sharded partitions its keys across independently locked shards by key
+// hash, so writers to keys in different shards do not contend.
+//
+// Each key carries a version, taken from its shard's write counter: it grows
+// with every write to the key and is never reused, even once the key has
+// been deleted and set again. Snapshot locks every shard, always in index
+// order, to read all keys at once.
+type Sharded struct {
+	seed   maphash.Seed
+	shards []shard
+}
+
+// cacheLine is the padding between shards, so that writers to neighbouring
+// shards do not share a cache line.
+const cacheLine = 64
+
+// shard is one independently locked partition of a Sharded.
+type shard struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	entries map[string]Snapshot // Allocated by the first write.
+	// +checklocks:mu
+	version uint64 // Incremented on every write to the shard.
+
+	_ [cacheLine]byte // Padding; not guarded by mu.
+}
+
+// NewSharded returns an empty Sharded with n shards. It panics if n is not
+// positive.
+func NewSharded(n int) *Sharded {
+	if n <= 0 {
+		panic("resource: NewSharded: shard count must be positive")
+	}
+	return &Sharded{seed: maphash.MakeSeed(), shards: make([]shard, n)}
+}
+
+// Shards returns the number of shards.
+func (s *Sharded) Shards() int {
+	return len(s.shards)
+}
+
+// shard returns the shard that key is routed to.
+func (s *Sharded) shard(key string) *shard {
+	return &s.shards[maphash.String(s.seed, key)%uint64(len(s.shards))]
+}
+
+// SetData sets the value and description of key, returning its new version.
+// Only the shard holding key is locked.
+func (s *Sharded) SetData(key string, val int, desc string) uint64 {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.setLocked(key, val, desc)
+}
+
+// GetData returns the value and description of key, and whether it is set.
+func (s *Sharded) GetData(key string) (int, string, bool) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	e, ok := sh.entries[key]
+	sh.mu.Unlock()
+	return e.Value, e.Description, ok
+}
+
+// Get returns the value, description and version of key read under a single
+// lock acquisition, and whether it is set.
+func (s *Sharded) Get(key string) (Snapshot, bool) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	e, ok := sh.entries[key]
+	return e, ok
+}
+
+// Delete removes key.
+func (s *Sharded) Delete(key string) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	if _, ok := sh.entries[key]; ok {
+		delete(sh.entries, key)
+		sh.version++
+	}
+	sh.mu.Unlock()
+}
+
+// setLocked writes key.
+// +checklocks:sh.mu
+func (sh *shard) setLocked(key string, val int, desc string) uint64 {
+	lockassert.Held(&sh.mu)
+	if sh.entries == nil {
+		sh.entries = make(map[string]Snapshot)
+	}
+	// Versions come from the shard counter rather than the key's previous
+	// entry, which Delete removes, so a recreated key does not restart at 1.
+	sh.version++
+	e := Snapshot{Value: val, Description: desc, Version: sh.version}
+	sh.entries[key] = e
+	return e.Version
+}
+
+// ShardedSnapshot is a consistent copy of every key in a Sharded.
+type ShardedSnapshot struct {
+	Entries map[string]Snapshot
+	// Version is the number of writes and deletes across all shards; two
+	// snapshots with the same Version hold the same data.
+	Version uint64
+}
+
+// Sum returns the sum of the values of all keys.
+func (s ShardedSnapshot) Sum() int {
+	sum := 0
+	for _, e := range s.Entries {
+		sum += e.Value
+	}
+	return sum
+}
+
+// Snapshot returns every key as of a single point in time. It locks the
+// shards one by one in index order and releases them only once all are held,
+// so no write lands between the first shard being read and the last; the
+// fixed order keeps concurrent snapshots from deadlocking each other.
+func (s *Sharded) Snapshot() ShardedSnapshot {
+	snap := ShardedSnapshot{Entries: make(map[string]Snapshot)}
+	s.snapshotFrom(0, &snap)
+	return snap
+}
+
+// snapshotFrom locks shard i, copies it into snap and, while still holding
+// it, does the same for the shards after it. The locks are released in
+// reverse order as the calls return.
+func (s *Sharded) snapshotFrom(i int, snap *ShardedSnapshot) {
+	if i == len(s.shards) {
+		return
+	}
+	sh := &s.shards[i]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	for k, e := range sh.entries {
+		snap.Entries[k] = e
+	}
+	snap.Version += sh.version
+	s.snapshotFrom(i+1, snap)
+}
@@ -0,0 +1,223 @@
+package resource
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestShardedSetGet(t *testing.T) {
+	s := NewSharded(8)
+	if s.Shards() != 8 {
+		t.Fatalf("Shards() = %d, want 8", s.Shards())
+	}
+	if _, _, ok := s.GetData("a"); ok {
+		t.Error("GetData on an empty Sharded reported a value")
+	}
+	if v := s.SetData("a", 1, "one"); v != 1 {
+		t.Errorf("first SetData returned version %d, want 1", v)
+	}
+	if v := s.SetData("a", 2, "two"); v != 2 {
+		t.Errorf("second SetData returned version %d, want 2", v)
+	}
+	s.SetData("b", 3, "three")
+	if v, d, ok := s.GetData("a"); !ok || v != 2 || d != "two" {
+		t.Errorf("GetData(a) = %d, %q, %v; want 2, two, true", v, d, ok)
+	}
+	if e, ok := s.Get("b"); !ok || e.Value != 3 || e.Description != "three" || e.Version == 0 {
+		t.Errorf("Get(b) = %+v, %v", e, ok)
+	}
+
+	s.Delete("a")
+	s.Delete("missing")
+	if _, _, ok := s.GetData("a"); ok {
+		t.Error("GetData(a) found a deleted key")
+	}
+	snap := s.Snapshot()
+	if len(snap.Entries) != 1 || snap.Sum() != 3 || snap.Version != 4 {
+		t.Errorf("Snapshot() = %+v, want only b and version 4", snap)
+	}
+}
+
+func TestShardedDeleteDoesNotReuseVersions(t *testing.T) {
+	s := NewSharded(1)
+	first := s.SetData("a", 1, "one")
+	s.Delete("a")
+	if v := s.SetData("a", 1, "one"); v <= first {
+		t.Errorf("SetData after Delete returned version %d, want more than %d", v, first)
+	}
+}
+
+func TestNewShardedPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewSharded(0) did not panic")
+		}
+	}()
+	NewSharded(0)
+}
+
+// keyIn returns a key routed to shard i of s.
+func keyIn(t *testing.T, s *Sharded, i int) string {
+	t.Helper()
+	for n := range 10000 {
+		k := fmt.Sprintf("key-%d", n)
+		if s.shard(k) == &s.shards[i] {
+			return k
+		}
+	}
+	t.Fatalf("no key found for shard %d", i)
+	return ""
+}
+
+// TestShardedSnapshotConsistent holds the last shard while a Snapshot is
+// taken: the snapshot must wait for it with every other shard locked, so it
+// sees the write made under the held lock and none made after it started.
+func TestShardedSnapshotConsistent(t *testing.T) {
+	s := NewSharded(4)
+	first, lastKey := keyIn(t, s, 0), keyIn(t, s, 3)
+	s.SetData(first, 1, "before")
+	s.SetData(lastKey, 1, "before")
+
+	last := &s.shards[3]
+	last.mu.Lock()
+	done := make(chan ShardedSnapshot)
+	go func() { done <- s.Snapshot() }()
+	// Wait for the snapshot to hold shard 0.
+	for !locked(&s.shards[0].mu) {
+		runtime.Gosched()
+	}
+	written := make(chan struct{})
+	go func() {
+		s.SetData(first, 2, "after")
+		close(written)
+	}()
+	last.setLocked(lastKey, 2, "while held")
+	last.mu.Unlock()
+
+	snap := <-done
+	<-written
+	if e := snap.Entries[first]; e.Value != 1 {
+		t.Errorf("snapshot of %s = %+v, want the value from before the snapshot", first, e)
+	}
+	if e := snap.Entries[lastKey]; e.Value != 2 {
+		t.Errorf("snapshot of %s = %+v, want the write made while the shard was held", lastKey, e)
+	}
+	if v, _, _ := s.GetData(first); v != 2 {
+		t.Errorf("GetData(%s) = %d after the snapshot, want 2", first, v)
+	}
+}
+
+func TestShardedConcurrent(t *testing.T) {
+	s := NewSharded(16)
+	const writers, writes = 8, 500
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range writes {
+				s.SetData(fmt.Sprintf("w%d-%d", w, i%32), i, "x")
+			}
+		}()
+	}
+	stop := make(chan struct{})
+	snapshots := make(chan error)
+	go func() {
+		var prev uint64
+		for {
+			select {
+			case <-stop:
+				close(snapshots)
+				return
+			default:
+			}
+			snap := s.Snapshot()
+			if snap.Version < prev {
+				snapshots <- fmt.Errorf("snapshot version went from %d to %d", prev, snap.Version)
+			}
+			prev = snap.Version
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	for err := range snapshots {
+		t.Error(err)
+	}
+
+	snap := s.Snapshot()
+	if snap.Version != writers*writes || len(snap.Entries) != writers*32 {
+		t.Errorf("final snapshot has version %d and %d keys, want %d and %d", snap.Version, len(snap.Entries), writers*writes, writers*32)
+	}
+}
+
+// benchGoroutines are the writer counts the benchmarks compare.
+var benchGoroutines = []int{1, 2, 4, 8, 16, 32, 64}
+
+// runGoroutines splits b.N calls of op across n goroutines. op is passed the
+// goroutine and iteration numbers.
+func runGoroutines(b *testing.B, n int, op func(g, i int)) {
+	var wg sync.WaitGroup
+	b.ResetTimer()
+	for g := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := g; i < b.N; i += n {
+				op(g, i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// BenchmarkSetData compares writes to a single ProtectedResource, which all
+// serialize on pr.mu, with writes spread over the keys of a Sharded.
+func BenchmarkSetData(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	for _, n := range benchGoroutines {
+		b.Run(fmt.Sprintf("ProtectedResource/goroutines=%d", n), func(b *testing.B) {
+			pr := newTestResource()
+			runGoroutines(b, n, func(_, i int) { pr.SetData(i, "bench") })
+		})
+		b.Run(fmt.Sprintf("Sharded/goroutines=%d", n), func(b *testing.B) {
+			s := NewSharded(runtime.GOMAXPROCS(0) * 4)
+			runGoroutines(b, n, func(g, i int) { s.SetData(keys[(g*31+i)%len(keys)], i, "bench") })
+		})
+	}
+}
+
+// BenchmarkSnapshot measures a consistent read of 1024 keys, against
+// ProtectedResource.Snapshot of its single value.
+func BenchmarkSnapshot(b *testing.B) {
+	b.Run("ProtectedResource", func(b *testing.B) {
+		pr := newTestResource()
+		for b.Loop() {
+			_ = pr.Snapshot()
+		}
+	})
+	b.Run("Sharded", func(b *testing.B) {
+		s := NewSharded(runtime.GOMAXPROCS(0) * 4)
+		for i := range 1024 {
+			s.SetData(fmt.Sprintf("key-%d", i), i, "bench")
+		}
+		for b.Loop() {
+			_ = s.Snapshot()
+		}
+	})
+}
+
+// locked reports whether mu is held by someone else. checklocks does not
+// track a successful TryLock, so the probe is not checked.
+// +checklocksignore
+func locked(mu *sync.Mutex) bool {
+	if !mu.TryLock() {
+		return true
+	}
+	mu.Unlock()
+	return false
+}
@@ -0,0 +1,59 @@
+package resource
+
+import "fmt"
+
+// --- Versioned Snapshots ---
+
+// Snapshot is a consistent copy of the mu-guarded data and the version it was
+// read at.
+type Snapshot struct {
+	Value       int
+	Description string
+	Version     uint64
+}
+
+// VersionConflictError is returned by SetDataIfVersion when the resource was
+// modified after the expected version was read.
+type VersionConflictError struct {
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("resource: version conflict: expected %d, current %d", e.Expected, e.Actual)
+}
+
+// Snapshot returns value, description and version read under a single lock
+// acquisition.
+func (pr *ProtectedResource) Snapshot() Snapshot {
+	pr.lockMu()
+	defer pr.unlockMu()
+	return Snapshot{
+		Value:       pr.value,
+		Description: pr.description,
+		Version:     pr.version,
+	}
+}
+
+// Version returns the current version.
+func (pr *ProtectedResource) Version() uint64 {
+	pr.lockMu()
+	defer pr.unlockMu()
+	return pr.version
+}
+
+// SetDataIfVersion sets value and description only if the current version is
+// expected, returning the new version. Otherwise it returns a
+// *VersionConflictError and leaves the resource unchanged.
+func (pr *ProtectedResource) SetDataIfVersion(expected uint64, val int, desc string) (uint64, error) {
+	pr.lockMu()
+	defer pr.unlockMu()
+	if pr.version != expected {
+		return pr.version, &VersionConflictError{Expected: expected, Actual: pr.version}
+	}
+	pr.setDataLocked(val, desc)
+	if pr.version == expected {
+		return pr.version, pr.Err() // The write was not logged, so it was dropped.
+	}
+	return pr.version, nil
+}
@@ -0,0 +1,83 @@
+package resource
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestSnapshotVersion(t *testing.T) {
+	pr := newTestResource()
+	s := pr.Snapshot()
+	if s.Value != 0 || s.Description != "initial" || s.Version != 0 {
+		t.Errorf("initial Snapshot: expected 0/initial/0, got %+v", s)
+	}
+
+	pr.SetData(1, "one")
+	pr.SetDataWithHelper(2, "two")
+	_ = pr.Update(func(v *LockedView) error {
+		v.SetValue(3)
+		v.SetDescription("three")
+		return nil
+	})
+	_ = pr.Update(func(v *LockedView) error { return nil }) // No write, no bump.
+
+	s = pr.Snapshot()
+	if s.Value != 3 || s.Description != "three" || s.Version != 3 {
+		t.Errorf("Snapshot: expected 3/three/3, got %+v", s)
+	}
+	if v := pr.Version(); v != 3 {
+		t.Errorf("Version: expected 3, got %d", v)
+	}
+}
+
+func TestSetDataIfVersion(t *testing.T) {
+	pr := newTestResource()
+	s := pr.Snapshot()
+
+	newVersion, err := pr.SetDataIfVersion(s.Version, 1, "first")
+	if err != nil {
+		t.Fatalf("SetDataIfVersion with current version failed: %v", err)
+	}
+	if newVersion != s.Version+1 {
+		t.Errorf("expected new version %d, got %d", s.Version+1, newVersion)
+	}
+
+	// A second writer holding the stale version must lose.
+	_, err = pr.SetDataIfVersion(s.Version, 2, "second")
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected *VersionConflictError, got %v", err)
+	}
+	if conflict.Expected != s.Version || conflict.Actual != newVersion {
+		t.Errorf("conflict: expected %d/%d, got %d/%d", s.Version, newVersion, conflict.Expected, conflict.Actual)
+	}
+	if val, desc := pr.GetData(); val != 1 || desc != "first" {
+		t.Errorf("conflicting write applied: got %d/%s", val, desc)
+	}
+}
+
+func TestSetDataIfVersionConcurrent(t *testing.T) {
+	pr := newTestResource()
+	const n = 20
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		wins int
+	)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := pr.SetDataIfVersion(0, i, "racer"); err == nil {
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if wins != 1 {
+		t.Errorf("expected exactly one winner, got %d", wins)
+	}
+}
@@ -0,0 +1,57 @@
+package resource
+
+import (
+	"context"
+
+	"github.com/kakkoyun/checklocks-demo/internal/lockassert"
+)
+
+// --- Waiting for a State ---
+
+// WaitUntil blocks until value and description satisfy pred, and returns
+// them. pred is called with the current data and again after every commit
+// to it by SetData, setDataLocked, an Update callback or decoding, until it
+// returns true. It is called without pr.mu held, so it may use pr, and it
+// may miss states that are overwritten before it runs.
+//
+// If ctx is done first, WaitUntil returns the last data pred rejected and
+// ctx.Err(). Nothing is left running after it returns.
+func (pr *ProtectedResource) WaitUntil(ctx context.Context, pred func(val int, desc string) bool) (int, string, error) {
+	for {
+		pr.lockMu()
+		val, desc := pr.value, pr.description
+		changed := pr.changedLocked()
+		pr.unlockMu()
+		if pred(val, desc) {
+			return val, desc, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return val, desc, ctx.Err()
+		}
+	}
+}
+
+// changedLocked returns a channel that is closed by the next commit to value
+// or description.
+// +checklocks:pr.mu
+func (pr *ProtectedResource) changedLocked() <-chan struct{} {
+	lockassert.Held(&pr.mu)
+	if pr.changed == nil {
+		pr.changed = make(chan struct{})
+	}
+	return pr.changed
+}
+
+// notifyLocked wakes every WaitUntil call waiting for a commit. The channel
+// is only allocated while someone waits, so commits without waiters cost a
+// nil check.
+// +checklocks:pr.mu
+func (pr *ProtectedResource) notifyLocked() {
+	lockassert.Held(&pr.mu)
+	if pr.changed != nil {
+		close(pr.changed)
+		pr.changed = nil
+	}
+}
@@ -0,0 +1,77 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWaitUntilImmediate(t *testing.T) {
+	pr := newTestResource()
+	val, desc, err := pr.WaitUntil(context.Background(), func(val int, desc string) bool { return desc == "initial" })
+	if err != nil || val != 0 || desc != "initial" {
+		t.Errorf("WaitUntil = %d, %q, %v; want 0, initial, nil", val, desc, err)
+	}
+}
+
+func TestWaitUntilWakesOnCommit(t *testing.T) {
+	pr := newTestResource()
+	type result struct {
+		val  int
+		desc string
+		err  error
+	}
+	done := make(chan result)
+	go func() {
+		val, desc, err := pr.WaitUntil(context.Background(), func(val int, _ string) bool { return val >= 3 })
+		done <- result{val, desc, err}
+	}()
+
+	pr.SetData(1, "one")
+	pr.SetDataWithHelper(2, "two")
+	select {
+	case r := <-done:
+		t.Fatalf("WaitUntil returned %+v before the predicate held", r)
+	case <-time.After(10 * time.Millisecond):
+	}
+	_ = pr.Update(func(v *LockedView) error {
+		v.SetValue(3)
+		v.SetDescription("three")
+		return nil
+	})
+	if r := <-done; r.err != nil || r.val != 3 || r.desc != "three" {
+		t.Errorf("WaitUntil = %+v, want 3, three", r)
+	}
+}
+
+func TestWaitUntilCanceled(t *testing.T) {
+	pr := newTestResource()
+	before := runtime.NumGoroutine()
+	for range 100 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		val, desc, err := pr.WaitUntil(ctx, func(int, string) bool { return false })
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) || val != 0 || desc != "initial" {
+			t.Fatalf("WaitUntil = %d, %q, %v; want the last data and DeadlineExceeded", val, desc, err)
+		}
+	}
+	pr.SetData(1, "one") // Wakes nobody.
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines before canceled waits, %d after", before, after)
+	}
+}
+
+func TestWaitUntilPredicateMayUseResource(t *testing.T) {
+	pr := newTestResource()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, _, err := pr.WaitUntil(ctx, func(int, string) bool {
+		_, _ = pr.GetData() // pr.mu is not held.
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,444 @@
+package resource
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kakkoyun/checklocks-demo/internal/wire"
+)
+
+// File names inside a durable resource's directory.
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot"
+)
+
+// walHeaderSize is the per-record frame: uint32 payload length, uint32 CRC
+// of the length and uint32 CRC of the payload. The length has its own CRC so
+// that a corrupt length is told apart from a record cut short by a crash.
+const walHeaderSize = 12
+
+// Record operations. Each record also carries a sequence number so replay
+// can skip records already folded into the snapshot.
+const (
+	opSetData byte = iota + 1
+	opSetID
+	opAddAtomic
+	opStoreMixed
+	opSetState
+)
+
+var (
+	// ErrClosed is returned for mutations on a durable resource after Close.
+	ErrClosed = errors.New("resource: closed")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// Options configures a durable resource created by Open.
+type Options struct {
+	// NoSync skips the fsync after each record. Writes survive a process
+	// crash but not a machine crash.
+	NoSync bool
+	// CompactInterval, if positive, compacts the log into the snapshot file
+	// periodically.
+	CompactInterval time.Duration
+	// CompactThreshold, if positive, compacts the log once it holds this
+	// many records.
+	CompactThreshold int
+}
+
+// wal is the append-only log backing a durable ProtectedResource. All
+// methods are safe to call on a nil receiver, which logs nothing, so the
+// in-memory resource pays a single nil check per mutation.
+type wal struct {
+	dir  string
+	opts Options
+
+	mu sync.Mutex
+	// +checklocks:mu
+	f *os.File
+	// +checklocks:mu
+	seq uint64 // Sequence number of the last appended record.
+	// +checklocks:mu
+	records int // Records appended since the last compaction.
+	// +checklocks:mu
+	err error // First append error; sticky.
+
+	kick chan struct{} // Wakes the compaction loop; capacity 1.
+	done chan struct{} // Closed by Close to stop the compaction loop.
+	wg   sync.WaitGroup
+}
+
+// Open returns a ProtectedResource whose SetData, SetID,
+// IncrementAtomicCorrect and WriteMixedCorrect calls, and the state set by
+// UnmarshalJSON, GobDecode and UnmarshalBinary, are recorded in an
+// append-only log in dir before they become visible. Existing state is
+// recovered from the snapshot file and the log; a torn record at the tail of
+// the log is truncated, but any other unreadable record makes Open fail
+// rather than discard the records after it. readGuardedValue is only
+// persisted by compaction and decoding, and acquireReleaseValue is not
+// persisted.
+//
+// If appending fails the mutation is dropped, and every later logged
+// mutation is dropped too; Err reports the cause. Call Close when done.
+func Open(dir string, opts Options) (*ProtectedResource, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s, seq, err := readSnapshot(filepath.Join(dir, snapshotFileName))
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	records, err := replay(f, &s, &seq)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	w := &wal{
+		dir:     dir,
+		opts:    opts,
+		f:       f,
+		seq:     seq,
+		records: records,
+		kick:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	pr := &ProtectedResource{}
+	pr.setState(s) // No log yet, so it cannot fail.
+	pr.wal = w
+	w.wg.Add(1)
+	go pr.compactLoop()
+	return pr, nil
+}
+
+// readSnapshot loads the snapshot file, returning the zero state if none exists.
+func readSnapshot(path string) (resourceState, uint64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return resourceState{}, 0, nil
+	}
+	if err != nil {
+		return resourceState{}, 0, err
+	}
+	if len(data) < 4 || crc32.Checksum(data[4:], crcTable) != binary.LittleEndian.Uint32(data) {
+		return resourceState{}, 0, fmt.Errorf("resource: corrupt snapshot %s", path)
+	}
+	d := wire.NewDecoder(data[4:])
+	seq := d.Uvarint()
+	s := decodeState(d)
+	if err := d.Finish(); err != nil {
+		return resourceState{}, 0, fmt.Errorf("resource: corrupt snapshot %s: %w", path, err)
+	}
+	return s, seq, nil
+}
+
+// replay applies every record in f after *seq to s, truncates a torn final
+// record and leaves the offset at the end. It returns the number of records
+// kept in the log.
+//
+// Only the final record can be torn by a crash during append: a header cut
+// short by the end of the file, a record whose intact header gives a length
+// running past the end, or a final record whose payload checksum does not
+// match. A header whose length fails its checksum, a payload checksum
+// mismatch before the final record, or a record that checks out but cannot
+// be applied, such as an op written by a newer version, is an error, since
+// truncating there would silently drop the valid records after it.
+func replay(f *os.File, s *resourceState, seq *uint64) (int, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return 0, err
+	}
+	var off, records int
+	for len(data)-off >= walHeaderSize {
+		if crc32.Checksum(data[off:off+4], crcTable) != binary.LittleEndian.Uint32(data[off+4:]) {
+			return 0, fmt.Errorf("resource: corrupt log record header at offset %d", off)
+		}
+		n := int(binary.LittleEndian.Uint32(data[off:]))
+		sum := binary.LittleEndian.Uint32(data[off+8:])
+		if n > len(data)-off-walHeaderSize {
+			break // Torn tail: the final record is short.
+		}
+		end := off + walHeaderSize + n
+		if crc32.Checksum(data[off+walHeaderSize:end], crcTable) != sum {
+			if end == len(data) {
+				break // Torn tail: the final record is incomplete.
+			}
+			return 0, fmt.Errorf("resource: corrupt log record at offset %d", off)
+		}
+		if err := applyRecord(data[off+walHeaderSize:end], s, seq); err != nil {
+			return 0, fmt.Errorf("resource: log record at offset %d: %w", off, err)
+		}
+		off = end
+		records++
+	}
+	if off != len(data) {
+		if err := f.Truncate(int64(off)); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := f.Seek(int64(off), io.SeekStart); err != nil {
+		return 0, err
+	}
+	return records, nil
+}
+
+// applyRecord folds one record into s, mirroring the live mutation.
+func applyRecord(payload []byte, s *resourceState, seq *uint64) error {
+	d := wire.NewDecoder(payload)
+	recSeq := d.Uvarint()
+	op := d.Uvarint()
+	var apply func()
+	switch byte(op) {
+	case opSetData:
+		val, desc := int(d.Varint()), d.String()
+		apply = func() {
+			s.Value, s.Description = val, desc
+			s.Version++
+		}
+	case opSetID:
+		id := d.String()
+		apply = func() { s.ID = id }
+	case opAddAtomic:
+		delta := int32(d.Varint())
+		apply = func() { s.AtomicValue += delta }
+	case opStoreMixed:
+		v := int32(d.Varint())
+		apply = func() { s.MixedValue = v }
+	case opSetState:
+		st := decodeState(d)
+		apply = func() { *s = st }
+	default:
+		return fmt.Errorf("resource: unknown log op %d", op)
+	}
+	if err := d.Finish(); err != nil {
+		return err
+	}
+	if recSeq > *seq {
+		apply()
+		*seq = recSeq
+	}
+	return nil
+}
+
+// append writes one record built by enc and, if that succeeds, runs apply
+// while still holding w.mu so log order matches apply order. It reports
+// whether the mutation was logged; a nil w logs nothing and always applies.
+func (w *wal) append(op byte, enc func(*wire.Encoder), apply func()) bool {
+	if w == nil {
+		if apply != nil {
+			apply()
+		}
+		return true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return false
+	}
+	if w.f == nil {
+		w.err = ErrClosed
+		return false
+	}
+
+	e := wire.Encoder{Buf: make([]byte, walHeaderSize, 64)}
+	e.Uvarint(w.seq + 1)
+	e.Uvarint(uint64(op))
+	enc(&e)
+	frame(e.Buf)
+	if _, err := w.f.Write(e.Buf); err != nil {
+		w.err = err
+		return false
+	}
+	if !w.opts.NoSync {
+		if err := w.f.Sync(); err != nil {
+			w.err = err
+			return false
+		}
+	}
+	w.seq++
+	w.records++
+	if w.opts.CompactThreshold > 0 && w.records >= w.opts.CompactThreshold {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	if apply != nil {
+		apply()
+	}
+	return true
+}
+
+// frame fills in the header of rec, whose payload starts at walHeaderSize.
+func frame(rec []byte) {
+	payload := rec[walHeaderSize:]
+	binary.LittleEndian.PutUint32(rec, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(rec[4:], crc32.Checksum(rec[:4], crcTable))
+	binary.LittleEndian.PutUint32(rec[8:], crc32.Checksum(payload, crcTable))
+}
+
+func (w *wal) logSetData(val int, desc string) bool {
+	return w.append(opSetData, func(e *wire.Encoder) {
+		e.Varint(int64(val))
+		e.String(desc)
+	}, nil)
+}
+
+func (w *wal) logStoreMixed(v int32) bool {
+	return w.append(opStoreMixed, func(e *wire.Encoder) { e.Varint(int64(v)) }, nil)
+}
+
+func (w *wal) logSetID(id string, apply func()) bool {
+	return w.append(opSetID, func(e *wire.Encoder) { e.String(id) }, apply)
+}
+
+func (w *wal) logAddAtomic(delta int32, apply func()) bool {
+	return w.append(opAddAtomic, func(e *wire.Encoder) { e.Varint(int64(delta)) }, apply)
+}
+
+// logSetState logs s in full, except acquireReleaseValue, which is not
+// persisted.
+func (w *wal) logSetState(s resourceState, apply func()) bool {
+	s.AcquireReleaseValue = 0
+	return w.append(opSetState, func(e *wire.Encoder) { encodeState(e, s) }, apply)
+}
+
+// compactLoop runs Compact on the configured interval and whenever append
+// reports the threshold was reached, until Close.
+func (pr *ProtectedResource) compactLoop() {
+	w := pr.wal
+	defer w.wg.Done()
+	var tick <-chan time.Time
+	if w.opts.CompactInterval > 0 {
+		t := time.NewTicker(w.opts.CompactInterval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-tick:
+		case <-w.kick:
+		}
+		_ = pr.Compact() // A failure is retried next round; the log stays authoritative.
+	}
+}
+
+// Compact writes the state to the snapshot file and empties the log. It
+// holds mu, rwMu (read) and the log's lock while doing so, so writers wait
+// for it. acquireReleaseValue is left out, so compaction never waits for a
+// caller holding acquireReleaseMu, and so never stalls the writers behind
+// it or Close. It is a no-op for a resource not created by Open.
+func (pr *ProtectedResource) Compact() error {
+	w := pr.wal
+	if w == nil {
+		return nil
+	}
+	snapshot := filepath.Join(w.dir, snapshotFileName) // dir is set once by Open.
+	// Lock order: mu, rwMu, then the log. Logged writers to mu-guarded
+	// fields append while holding mu; SetID and IncrementAtomicCorrect apply
+	// while holding w.mu.
+	pr.lockMu()
+	defer pr.unlockMu()
+	held := pr.rlockRWMu()
+	defer pr.runlockRWMu(held)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return ErrClosed
+	}
+
+	e := wire.Encoder{Buf: make([]byte, 4, 128)}
+	e.Uvarint(w.seq)
+	encodeState(&e, pr.stateLocked())
+	binary.LittleEndian.PutUint32(e.Buf, crc32.Checksum(e.Buf[4:], crcTable))
+	if err := writeFileAtomic(snapshot, e.Buf); err != nil {
+		return err
+	}
+	// The snapshot records w.seq, so a crash before the truncation below
+	// only leaves records that replay will skip.
+	if err := w.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w.records = 0
+	return nil
+}
+
+// writeFileAtomic replaces path with data via a synced temporary file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Err returns the first error hit while appending to the log, after which
+// logged mutations are dropped. It is always nil for a resource not created
+// by Open.
+func (pr *ProtectedResource) Err() error {
+	w := pr.wal
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close stops background compaction and closes the log. Later logged
+// mutations are dropped and Err reports ErrClosed. It is a no-op for a
+// resource not created by Open.
+func (pr *ProtectedResource) Close() error {
+	w := pr.wal
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	f := w.f
+	w.f = nil
+	w.mu.Unlock()
+	if f == nil {
+		return ErrClosed
+	}
+	close(w.done)
+	w.wg.Wait()
+	return f.Close()
+}
@@ -0,0 +1,288 @@
+package resource
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kakkoyun/checklocks-demo/internal/wire"
+)
+
+func openTestResource(t *testing.T, dir string, opts Options) *ProtectedResource {
+	t.Helper()
+	pr, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return pr
+}
+
+func mutateDurable(pr *ProtectedResource) {
+	pr.SetData(7, "durable")
+	pr.SetID("id-wal")
+	pr.IncrementAtomicCorrect()
+	pr.IncrementAtomicCorrect()
+	pr.WriteMixedCorrect(31)
+}
+
+func checkDurable(t *testing.T, pr *ProtectedResource) {
+	t.Helper()
+	s := pr.state()
+	want := resourceState{Value: 7, Description: "durable", Version: 1, ID: "id-wal", AtomicValue: 2, MixedValue: 31}
+	if s != want {
+		t.Errorf("recovered state mismatch:\n got %+v\nwant %+v", s, want)
+	}
+}
+
+func TestOpenRecoversFromLog(t *testing.T) {
+	dir := t.TempDir()
+	pr := openTestResource(t, dir, Options{})
+	mutateDurable(pr)
+	if err := pr.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	pr = openTestResource(t, dir, Options{})
+	defer pr.Close()
+	checkDurable(t, pr)
+}
+
+func TestOpenTruncatesTornTail(t *testing.T) {
+	// A header with its length intact, promising 20 payload bytes.
+	header := make([]byte, walHeaderSize, walHeaderSize+20)
+	binary.LittleEndian.PutUint32(header, 20)
+	binary.LittleEndian.PutUint32(header[4:], crc32.Checksum(header[:4], crcTable))
+	for name, tail := range map[string][]byte{
+		"short header":  {20, 0, 0, 0, 1},
+		"short payload": append(header, 1, 2, 3, 4, 5),
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			pr := openTestResource(t, dir, Options{})
+			mutateDurable(pr)
+			pr.Close()
+
+			logPath := filepath.Join(dir, walFileName)
+			good, err := os.Stat(logPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Simulate a crash in the middle of appending a record.
+			f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write(tail)
+			f.Close()
+
+			pr = openTestResource(t, dir, Options{})
+			checkDurable(t, pr)
+			if st, _ := os.Stat(logPath); st.Size() != good.Size() {
+				t.Errorf("torn tail not truncated: size %d, want %d", st.Size(), good.Size())
+			}
+			// New records must land after the truncation point and survive.
+			pr.SetData(8, "after torn tail")
+			pr.Close()
+			pr = openTestResource(t, dir, Options{})
+			defer pr.Close()
+			if val, desc := pr.GetData(); val != 8 || desc != "after torn tail" {
+				t.Errorf("expected 8/after torn tail, got %d/%s", val, desc)
+			}
+		})
+	}
+}
+
+func TestOpenRejectsCorruptRecord(t *testing.T) {
+	// Flip a byte of the first record; valid records follow it. A corrupt
+	// length must not pass for a record running past the end of the log.
+	for name, off := range map[string]int{
+		"length":  3,
+		"payload": walHeaderSize,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			pr := openTestResource(t, dir, Options{})
+			mutateDurable(pr)
+			pr.Close()
+
+			logPath := filepath.Join(dir, walFileName)
+			data, err := os.ReadFile(logPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data[off] ^= 0xff
+			if err := os.WriteFile(logPath, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if pr, err := Open(dir, Options{}); err == nil {
+				pr.Close()
+				t.Fatal("Open accepted a log with a corrupt record before its tail")
+			}
+			if st, _ := os.Stat(logPath); st.Size() != int64(len(data)) {
+				t.Errorf("failed Open truncated the log to %d bytes, want %d", st.Size(), len(data))
+			}
+		})
+	}
+}
+
+func TestOpenRejectsUnknownOp(t *testing.T) {
+	dir := t.TempDir()
+	pr := openTestResource(t, dir, Options{})
+	mutateDurable(pr)
+	pr.Close()
+
+	// Append a well-formed record with an op this version does not know,
+	// as a newer binary might have written.
+	e := wire.Encoder{Buf: make([]byte, walHeaderSize)}
+	e.Uvarint(100)
+	e.Uvarint(99)
+	frame(e.Buf)
+	f, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(e.Buf)
+	f.Close()
+
+	if pr, err := Open(dir, Options{}); err == nil {
+		pr.Close()
+		t.Fatal("Open accepted a record with an unknown op")
+	}
+}
+
+func TestUnmarshalIsLogged(t *testing.T) {
+	src := &ProtectedResource{}
+	mutateDurable(src)
+	data, err := src.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	pr := openTestResource(t, dir, Options{})
+	pr.SetData(1, "overwritten")
+	if err := pr.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	pr.Close()
+	if err := pr.UnmarshalJSON([]byte(`{"value":9}`)); !errors.Is(err, ErrClosed) {
+		t.Errorf("UnmarshalJSON after Close: expected ErrClosed, got %v", err)
+	}
+	checkDurable(t, pr)
+
+	pr = openTestResource(t, dir, Options{})
+	defer pr.Close()
+	checkDurable(t, pr)
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	pr := openTestResource(t, dir, Options{})
+	mutateDurable(pr)
+	logPath := filepath.Join(dir, walFileName)
+	preCompact, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pr.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if st, _ := os.Stat(logPath); st.Size() != 0 {
+		t.Errorf("log not emptied by Compact: size %d", st.Size())
+	}
+	pr.Close()
+
+	// Simulate a crash after the snapshot was written but before the log
+	// was truncated: already-compacted records must not be applied twice.
+	if err := os.WriteFile(logPath, preCompact, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pr = openTestResource(t, dir, Options{})
+	defer pr.Close()
+	checkDurable(t, pr)
+}
+
+func TestCompactThreshold(t *testing.T) {
+	dir := t.TempDir()
+	pr := openTestResource(t, dir, Options{NoSync: true, CompactThreshold: 3})
+	defer pr.Close()
+	mutateDurable(pr)
+
+	snapshot := filepath.Join(dir, snapshotFileName)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(snapshot); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background compaction never wrote a snapshot")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCompactDoesNotWaitForAcquire(t *testing.T) {
+	pr := openTestResource(t, t.TempDir(), Options{NoSync: true, CompactThreshold: 1})
+	pr.AcquireAndSet(1)
+	defer pr.GetAndRelease()
+
+	done := make(chan error)
+	go func() {
+		// Each write kicks a background compaction.
+		for i := range 5 {
+			pr.SetData(i, "while acquired")
+		}
+		done <- pr.Close()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("writes or Close blocked behind compaction waiting for acquireReleaseMu")
+	}
+}
+
+func TestClosedDropsWrites(t *testing.T) {
+	pr := openTestResource(t, t.TempDir(), Options{})
+	pr.SetData(1, "before close")
+	if err := pr.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	pr.SetData(2, "after close")
+	if val, _ := pr.GetData(); val != 1 {
+		t.Errorf("write after Close applied: got %d", val)
+	}
+	if err := pr.Err(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Err: expected ErrClosed, got %v", err)
+	}
+	err := pr.Update(func(v *LockedView) error {
+		v.SetValue(3)
+		return nil
+	})
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("Update after Close: expected ErrClosed, got %v", err)
+	}
+	if val, _ := pr.GetData(); val != 1 {
+		t.Errorf("Update after Close not reverted: got %d", val)
+	}
+}
+
+func TestInMemoryHasNoLog(t *testing.T) {
+	pr := newTestResource()
+	if err := pr.Compact(); err != nil {
+		t.Errorf("Compact on in-memory resource: %v", err)
+	}
+	if err := pr.Close(); err != nil {
+		t.Errorf("Close on in-memory resource: %v", err)
+	}
+	pr.SetData(1, "still works")
+	if val, _ := pr.GetData(); val != 1 {
+		t.Errorf("in-memory SetData after Close: expected 1, got %d", val)
+	}
+}
@@ -0,0 +1,66 @@
+package resource
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/kakkoyun/checklocks-demo/internal/watch"
+)
+
+// Change describes a committed write to one field of a ProtectedResource.
+// Version increases by one for every change to the resource, so a gap in
+// the versions a watcher receives means events were dropped.
+//
+// ResourceVersion is the resource's version, as in Snapshot, once the write
+// is committed: SetData and Update bump it once, so their value and
+// description changes share it, and writes to other fields carry the
+// current version without bumping it.
+type Change struct {
+	Field           string
+	Old             any
+	New             any
+	Version         uint64
+	ResourceVersion uint64
+}
+
+// WatchOption configures a single Watch subscription.
+type WatchOption = watch.Option
+
+// SlowConsumerPolicy decides what happens when a watcher's buffer is full.
+type SlowConsumerPolicy = watch.Policy
+
+const (
+	// DropOldest discards the oldest buffered change; writers never block.
+	// This is the default.
+	DropOldest = watch.DropOldest
+	// Block makes writers wait, while holding the resource's lock, until the
+	// watcher has room or its context is done.
+	Block = watch.Block
+)
+
+// WithWatchBuffer sets the watcher's channel capacity (default 16).
+func WithWatchBuffer(n int) WatchOption {
+	return watch.WithBuffer(n)
+}
+
+// WithWatchPolicy sets the watcher's slow-consumer policy (default DropOldest).
+func WithWatchPolicy(p SlowConsumerPolicy) WatchOption {
+	return watch.WithPolicy(p)
+}
+
+// Watch returns a channel that receives a Change every time SetData,
+// setDataLocked, AcquireAndSet, AcquireAndSetCtx, Lease.Set,
+// WriteMixedCorrect or an Update callback commits a write. The channel is closed once ctx is done.
+func (pr *ProtectedResource) Watch(ctx context.Context, opts ...WatchOption) <-chan Change {
+	return pr.watchers.Subscribe(ctx, opts...)
+}
+
+// publish notifies watchers of a write. Callers hold the lock guarding
+// field, so changes to the same field are published in commit order;
+// callers committing value or description bump the resource version first.
+func (pr *ProtectedResource) publish(field string, old, new any) {
+	resourceVersion := atomic.LoadUint64(&pr.version)
+	pr.watchers.Publish(func(version uint64) Change {
+		return Change{Field: field, Old: old, New: new, Version: version, ResourceVersion: resourceVersion}
+	})
+}
@@ -0,0 +1,77 @@
+package resource
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWatchReceivesChanges(t *testing.T) {
+	pr := newTestResource()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := pr.Watch(ctx)
+
+	pr.SetData(1, "one")
+	pr.WriteMixedCorrect(31)
+	pr.AcquireAndSet(41)
+	_ = pr.GetAndRelease()
+	if err := pr.AcquireAndSetCtx(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+	_ = pr.GetAndRelease() // +checklocksforce:pr.acquireReleaseMu
+
+	want := []Change{
+		{Field: "value", Old: 0, New: 1, Version: 1, ResourceVersion: 1},
+		{Field: "description", Old: "initial", New: "one", Version: 2, ResourceVersion: 1},
+		{Field: "mixedValue", Old: int32(30), New: int32(31), Version: 3, ResourceVersion: 1},
+		{Field: "acquireReleaseValue", Old: 40, New: 41, Version: 4, ResourceVersion: 1},
+		{Field: "acquireReleaseValue", Old: 41, New: 42, Version: 5, ResourceVersion: 1},
+	}
+	for _, w := range want {
+		if got := <-ch; got != w {
+			t.Errorf("expected %+v, got %+v", w, got)
+		}
+	}
+	if v := pr.Version(); v != 1 {
+		t.Errorf("changes stamped with resource version 1, but Version() = %d", v)
+	}
+}
+
+func TestWatchUpdate(t *testing.T) {
+	pr := newTestResource()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := pr.Watch(ctx)
+
+	_ = pr.Update(func(v *LockedView) error {
+		v.SetValue(v.Value() + 5)
+		return nil
+	})
+	if got := <-ch; got.Field != "value" || got.Old != 0 || got.New != 5 || got.ResourceVersion != 1 {
+		t.Errorf("expected value 0->5 at resource version 1, got %+v", got)
+	}
+}
+
+func TestWatchDropOldest(t *testing.T) {
+	pr := newTestResource()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := pr.Watch(ctx, WithWatchBuffer(1), WithWatchPolicy(DropOldest))
+
+	for i := range 3 {
+		pr.WriteMixedCorrect(int32(i))
+	}
+	if got := <-ch; got.New != int32(2) {
+		t.Errorf("expected only the newest change, got %+v", got)
+	}
+}
+
+func TestWatchClosedOnCancel(t *testing.T) {
+	pr := newTestResource()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := pr.Watch(ctx)
+	cancel()
+	for range ch {
+	}
+	pr.SetData(1, "after cancel") // Must not block or panic.
+}